@@ -2,15 +2,18 @@ package main
 
 import (
 	"context"
+	"flag"
 	"log"
 	"net/http"
 	"os"
 	"strconv"
 	"time"
+	_ "time/tzdata" // CSV_TIMEZONE must resolve on alpine images without zoneinfo
 
 	"serumpun-data-api/internal/cache"
 	"serumpun-data-api/internal/db"
 	httpx "serumpun-data-api/internal/http"
+	"serumpun-data-api/internal/plane"
 	"serumpun-data-api/internal/queries"
 
 	"github.com/joho/godotenv"
@@ -25,6 +28,10 @@ func mustEnv(key string) string {
 }
 
 func main() {
+	installTriggers := flag.Bool("install-triggers", false, "install the LISTEN/NOTIFY triggers (queries/notify_triggers.sql) and exit")
+	takeSnapshot := flag.Bool("snapshot", false, "store today's KPI snapshot and exit")
+	flag.Parse()
+
 	_ = godotenv.Load()
 
 	port := os.Getenv("APP_PORT")
@@ -39,8 +46,93 @@ func main() {
 		}
 	}
 
+	cacheMaxEntries := cache.DefaultMaxEntries
+	if v := os.Getenv("CACHE_MAX_ENTRIES"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cacheMaxEntries = n
+		}
+	}
+	var cacheMaxBytes int64 = cache.DefaultMaxBytes
+	if v := os.Getenv("CACHE_MAX_BYTES"); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil && n > 0 {
+			cacheMaxBytes = n
+		}
+	}
+	// Stale windows after the TTL: serve-and-revalidate, and serve-on-error
+	staleSec := 300
+	if v := os.Getenv("CACHE_STALE_SECONDS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			staleSec = n
+		}
+	}
+	maxStaleSec := 3600
+	if v := os.Getenv("CACHE_MAX_STALE_SECONDS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			maxStaleSec = n
+		}
+	}
+	janitorSec := 60
+	if v := os.Getenv("CACHE_JANITOR_SECONDS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			janitorSec = n
+		}
+	}
+
+	// Cache invalidation through LISTEN/NOTIFY (needs -install-triggers once)
+	notifyEnabled, _ := strconv.ParseBool(os.Getenv("NOTIFY_ENABLED"))
+	notifyChannel := os.Getenv("NOTIFY_CHANNEL")
+	if notifyChannel == "" {
+		notifyChannel = "serumpun_changes"
+	}
+	if !db.ValidChannel(notifyChannel) {
+		log.Fatalf("NOTIFY_CHANNEL: invalid channel name %q", notifyChannel)
+	}
+	notifyMode := os.Getenv("NOTIFY_MODE")
+	switch notifyMode {
+	case "":
+		notifyMode = httpx.NotifyInvalidate
+	case httpx.NotifyInvalidate, httpx.NotifyPrewarm:
+	default:
+		log.Fatalf("NOTIFY_MODE: unsupported mode %q (supported: invalidate, prewarm)", notifyMode)
+	}
+	notifyDebounceMs := 2000
+	if v := os.Getenv("NOTIFY_DEBOUNCE_MS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			notifyDebounceMs = n
+		}
+	}
+
+	// Daily KPI snapshots (for ?compare=), taken after SNAPSHOT_TIME (HH:MM, CSV timezone)
+	snapshotEnabled, _ := strconv.ParseBool(os.Getenv("SNAPSHOT_ENABLED"))
+	snapshotAt := 23 * time.Hour
+	if v := os.Getenv("SNAPSHOT_TIME"); v != "" {
+		t, err := time.Parse("15:04", v)
+		if err != nil {
+			log.Fatalf("SNAPSHOT_TIME: expected HH:MM, got %q", v)
+		}
+		snapshotAt = time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	}
+
 	databaseURL := mustEnv("DATABASE_URL")
 
+	// Plane project(s): PLANE_PROJECTS lists every project selectable via
+	// ?project=; without it a single project comes from PLANE_WORKSPACE_ID/PLANE_PROJECT_ID.
+	projectList, err := plane.ParseProjects(os.Getenv("PLANE_PROJECTS"))
+	if err != nil {
+		log.Fatalf("PLANE_PROJECTS: %v", err)
+	}
+	if len(projectList) == 0 {
+		projectList = []plane.Project{{
+			Key:         "default",
+			WorkspaceID: mustEnv("PLANE_WORKSPACE_ID"),
+			ProjectID:   mustEnv("PLANE_PROJECT_ID"),
+		}}
+	}
+	projects, err := plane.NewRegistry(projectList, os.Getenv("PLANE_DEFAULT_PROJECT"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	ctx := context.Background()
 	pool, err := db.NewPool(ctx, databaseURL)
 	if err != nil {
@@ -48,10 +140,148 @@ func main() {
 	}
 	defer pool.Close()
 
+	if *installTriggers {
+		script, err := queries.New("queries").Load("notify_triggers.sql")
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := db.InstallTriggers(ctx, pool, script, notifyChannel); err != nil {
+			log.Fatalf("install triggers: %v", err)
+		}
+		log.Printf("notify triggers installed on %v (channel %q)", db.NotifyTables, notifyChannel)
+		return
+	}
+
+	// Staff directory: parsed once, then reloaded when the file changes
+	directoryPath := os.Getenv("DIRECTORY_CSV_PATH")
+	if directoryPath == "" {
+		directoryPath = "data/daftar_pengguna_serumpun.csv"
+	}
+	// Role taxonomy: ordered jabatan rules, loaded once at startup
+	rulesPath := os.Getenv("JABATAN_RULES_PATH")
+	if rulesPath == "" {
+		rulesPath = "data/jabatan_rules.csv"
+	}
+	roleRules, err := httpx.LoadRoleRules(rulesPath)
+	if err != nil {
+		log.Fatalf("load jabatan rules: %v", err)
+	}
+
+	// Kab/kota registry (BPS code -> name, instansi -> region)
+	wilayahPath := os.Getenv("WILAYAH_CSV_PATH")
+	if wilayahPath == "" {
+		wilayahPath = "data/wilayah.csv"
+	}
+	regions, err := httpx.LoadRegionsFromCSV(wilayahPath)
+	if err != nil {
+		log.Fatalf("load wilayah: %v", err)
+	}
+
+	// Validation policy for the directory CSV (startup and reloads)
+	directoryPolicy, err := httpx.ParseValidationPolicy(os.Getenv("DIRECTORY_VALIDATION_POLICY"))
+	if err != nil {
+		log.Fatalf("DIRECTORY_VALIDATION_POLICY: %v", err)
+	}
+	directory, err := httpx.NewDirectory(directoryPath, roleRules, regions, directoryPolicy)
+	if err != nil {
+		log.Fatalf("load directory: %v", err)
+	}
+
+	pollSec := 30
+	if v := os.Getenv("DIRECTORY_POLL_SECONDS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			pollSec = n
+		}
+	}
+
+	streamCacheMax := 0
+	if v := os.Getenv("STREAM_CACHE_MAX_BYTES"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			streamCacheMax = n
+		}
+	}
+
+	// CSV value formatting
+	csvFormat := httpx.DefaultCSVFormat()
+	if v := os.Getenv("CSV_TIMEZONE"); v != "" {
+		loc, err := time.LoadLocation(v)
+		if err != nil {
+			log.Fatalf("CSV_TIMEZONE: %v", err)
+		}
+		csvFormat.Location = loc
+	}
+	if v := os.Getenv("CSV_NUMERIC_PRECISION"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			csvFormat.Precision = n
+		}
+	}
+	if csvFormat.Locale, err = httpx.ParseLocale(os.Getenv("CSV_LOCALE")); err != nil {
+		log.Fatalf("CSV_LOCALE: %v", err)
+	}
+
+	respCache := cache.NewBounded(cache.Config{
+		TTL:        time.Duration(ttlSec) * time.Second,
+		StaleTTL:   time.Duration(staleSec) * time.Second,
+		MaxStale:   time.Duration(maxStaleSec) * time.Second,
+		MaxEntries: cacheMaxEntries,
+		MaxBytes:   cacheMaxBytes,
+	})
+	go respCache.Janitor(ctx, time.Duration(janitorSec)*time.Second)
+
+	// Cache-Control max-age (HTTP freshness), default and per endpoint
+	maxAge := 30
+	if v := os.Getenv("HTTP_MAX_AGE"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			maxAge = n
+		}
+	}
+	endpointMaxAge, err := httpx.ParseMaxAges(os.Getenv("HTTP_MAX_AGE_ENDPOINTS"))
+	if err != nil {
+		log.Fatalf("HTTP_MAX_AGE_ENDPOINTS: %v", err)
+	}
+
 	srv := &httpx.Server{
-		DB:      pool,
-		Cache:   cache.New(time.Duration(ttlSec) * time.Second),
-		Queries: queries.New("queries"),
+		DB:        pool,
+		Cache:     respCache,
+		Queries:   queries.New("queries"),
+		Projects:  projects,
+		Directory: directory,
+		Regions:   regions,
+
+		CSV:                 csvFormat,
+		StreamCacheMaxBytes: streamCacheMax,
+		MaxAge:              maxAge,
+		EndpointMaxAge:      endpointMaxAge,
+		AdminToken:          os.Getenv("ADMIN_TOKEN"),
+	}
+
+	if *takeSnapshot || snapshotEnabled {
+		script, err := srv.Queries.Load("snapshot_schema.sql")
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := db.EnsureSnapshotSchema(ctx, pool, script); err != nil {
+			log.Fatalf("snapshot schema: %v", err)
+		}
+	}
+	if *takeSnapshot {
+		if err := srv.TakeSnapshots(ctx); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if snapshotEnabled {
+		go srv.RunSnapshots(ctx, snapshotAt, 10*time.Minute)
+	}
+
+	if notifyEnabled {
+		srv.Notifier = httpx.NewChangeNotifier(srv, notifyChannel, notifyMode, time.Duration(notifyDebounceMs)*time.Millisecond)
+		srv.Notifier.Start(ctx, databaseURL)
+	}
+
+	directory.OnReload(srv.InvalidateDirectoryCache)
+	if pollSec > 0 {
+		go directory.Watch(ctx, time.Duration(pollSec)*time.Second)
 	}
 
 	h := httpx.NewRouter(srv)
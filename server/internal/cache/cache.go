@@ -1,37 +1,370 @@
 package cache
 
 import (
+	"container/list"
+	"context"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// Default limits used by New
+const (
+	DefaultMaxEntries = 1000
+	DefaultMaxBytes   = 128 << 20
+)
+
 type Item struct {
-	Data      []byte
-	ExpiresAt time.Time
+	Data       []byte
+	CreatedAt  time.Time
+	FreshUntil time.Time // served as a hit until here
+	StaleUntil time.Time // served stale while revalidating until here
+	ExpiresAt  time.Time // kept for stale-if-error until here, then removed
+	Modified   time.Time // when the underlying data last changed (zero = unknown)
+}
+
+// Age is how long ago the item was stored
+func (it Item) Age() time.Duration {
+	return time.Since(it.CreatedAt)
+}
+
+// State is the freshness of a looked up entry
+type State int
+
+const (
+	Miss         State = iota // not cached (or past ExpiresAt)
+	Fresh                     // within TTL
+	Stale                     // past TTL, within StaleTTL: serve and revalidate
+	StaleIfError              // past StaleTTL, within MaxStale: only serve if refreshing fails
+)
+
+func (st State) String() string {
+	switch st {
+	case Fresh:
+		return "fresh"
+	case Stale:
+		return "stale"
+	case StaleIfError:
+		return "stale-if-error"
+	}
+	return "miss"
 }
 
+// entry is an Item in the LRU list
+type entry struct {
+	key string
+	Item
+}
+
+// Config bounds a Cache. Zero limits mean unbounded.
+type Config struct {
+	TTL        time.Duration
+	StaleTTL   time.Duration // stale-while-revalidate window after TTL
+	MaxStale   time.Duration // stale-if-error window after TTL
+	MaxEntries int
+	MaxBytes   int64
+}
+
+// Cache is a TTL cache bounded by entry count and total payload bytes.
+// When a limit is exceeded the least recently used entries are evicted.
+// Entries past TTL stay available through Lookup for the stale windows and
+// are removed on access and by Janitor once those have passed as well.
 type Cache struct {
-	mu   sync.RWMutex
-	ttl  time.Duration
-	data map[string]Item
+	mu    sync.Mutex
+	cfg   Config
+	data  map[string]*list.Element
+	lru   *list.List // front = most recently used
+	bytes int64
+
+	onRemove func(key string)
+
+	hits        atomic.Uint64
+	staleHits   atomic.Uint64
+	misses      atomic.Uint64
+	evictions   atomic.Uint64
+	expirations atomic.Uint64
 }
 
+// New returns a cache with the default limits
 func New(ttl time.Duration) *Cache {
-	return &Cache{ttl: ttl, data: map[string]Item{}}
+	return NewBounded(Config{TTL: ttl, MaxEntries: DefaultMaxEntries, MaxBytes: DefaultMaxBytes})
+}
+
+// NewBounded returns a cache with explicit limits
+func NewBounded(cfg Config) *Cache {
+	return &Cache{cfg: cfg, data: map[string]*list.Element{}, lru: list.New()}
 }
 
+// OnRemove sets fn to be called with the key of every entry that leaves the
+// cache: evicted, expired or deleted, but not replaced by Set. fn runs with
+// the cache locked and must not call back into it.
+func (c *Cache) OnRemove(fn func(key string)) {
+	c.mu.Lock()
+	c.onRemove = fn
+	c.mu.Unlock()
+}
+
+// Get returns the payload only while it is fresh
 func (c *Cache) Get(key string) ([]byte, bool) {
-	c.mu.RLock()
-	it, ok := c.data[key]
-	c.mu.RUnlock()
-	if !ok || time.Now().After(it.ExpiresAt) {
+	it, st := c.Lookup(key)
+	if st != Fresh {
 		return nil, false
 	}
 	return it.Data, true
 }
 
+// Lookup returns the entry for key and how fresh it is. Stale entries are
+// returned too; the caller decides whether to serve them.
+func (c *Cache) Lookup(key string) (Item, State) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.data[key]
+	if !ok {
+		c.misses.Add(1)
+		return Item{}, Miss
+	}
+	e := el.Value.(*entry)
+	now := time.Now()
+	if now.After(e.ExpiresAt) {
+		c.remove(el)
+		c.expirations.Add(1)
+		c.misses.Add(1)
+		return Item{}, Miss
+	}
+	c.lru.MoveToFront(el)
+
+	st := e.state(now)
+	switch st {
+	case Fresh:
+		c.hits.Add(1)
+	case Stale:
+		c.staleHits.Add(1)
+	default:
+		c.misses.Add(1)
+	}
+	return e.Item, st
+}
+
+// Set stores b under key. A payload larger than MaxBytes on its own is not
+// cached at all.
 func (c *Cache) Set(key string, b []byte) {
+	c.SetModified(key, b, time.Time{})
+}
+
+// SetModified is Set with the time the payload's source data last changed.
+// It reports whether the payload was stored.
+func (c *Cache) SetModified(key string, b []byte, modified time.Time) bool {
 	c.mu.Lock()
-	c.data[key] = Item{Data: b, ExpiresAt: time.Now().Add(c.ttl)}
+	defer c.mu.Unlock()
+
+	el, exists := c.data[key]
+	if c.cfg.MaxBytes > 0 && int64(len(b)) > c.cfg.MaxBytes {
+		if exists {
+			c.remove(el)
+		}
+		return false
+	}
+	if exists {
+		c.unlink(el)
+	}
+
+	now := time.Now()
+	it := Item{
+		Data:       b,
+		CreatedAt:  now,
+		FreshUntil: now.Add(c.cfg.TTL),
+		StaleUntil: now.Add(c.cfg.TTL + c.cfg.StaleTTL),
+		ExpiresAt:  now.Add(c.cfg.TTL + max(c.cfg.StaleTTL, c.cfg.MaxStale)),
+		Modified:   modified,
+	}
+	el = c.lru.PushFront(&entry{key: key, Item: it})
+	c.data[key] = el
+	c.bytes += int64(len(b))
+
+	for c.overLimit() {
+		c.remove(c.lru.Back())
+		c.evictions.Add(1)
+	}
+	return true
+}
+
+// overLimit reports whether either limit is exceeded; callers hold mu
+func (c *Cache) overLimit() bool {
+	if c.lru.Len() == 0 {
+		return false
+	}
+	return (c.cfg.MaxEntries > 0 && c.lru.Len() > c.cfg.MaxEntries) ||
+		(c.cfg.MaxBytes > 0 && c.bytes > c.cfg.MaxBytes)
+}
+
+// remove drops an element and reports its key to OnRemove; callers hold mu
+func (c *Cache) remove(el *list.Element) {
+	key := c.unlink(el)
+	if c.onRemove != nil {
+		c.onRemove(key)
+	}
+}
+
+// unlink drops an element and returns its key; callers hold mu
+func (c *Cache) unlink(el *list.Element) string {
+	e := c.lru.Remove(el).(*entry)
+	delete(c.data, e.key)
+	c.bytes -= int64(len(e.Data))
+	return e.key
+}
+
+// state is the freshness of the item at now
+func (it Item) state(now time.Time) State {
+	switch {
+	case !now.After(it.FreshUntil):
+		return Fresh
+	case !now.After(it.StaleUntil):
+		return Stale
+	case !now.After(it.ExpiresAt):
+		return StaleIfError
+	}
+	return Miss
+}
+
+// DeletePrefix removes every entry whose key starts with prefix and returns
+// how many were removed.
+func (c *Cache) DeletePrefix(prefix string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for k, el := range c.data {
+		if strings.HasPrefix(k, prefix) {
+			c.remove(el)
+			n++
+		}
+	}
+	return n
+}
+
+// Expire ends the fresh and stale windows of every entry whose key starts
+// with prefix, so the next lookup rebuilds it. The payload stays available
+// as a stale-if-error fallback for MaxStale from now, never past its own
+// TTL+MaxStale; with MaxStale 0 it is not served again. It returns how many
+// were expired.
+func (c *Cache) Expire(prefix string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	n := 0
+	for k, el := range c.data {
+		if !strings.HasPrefix(k, prefix) {
+			continue
+		}
+		e := el.Value.(*entry)
+		if e.StaleUntil.After(now) {
+			e.FreshUntil, e.StaleUntil = now, now
+			limit := e.CreatedAt.Add(c.cfg.TTL + c.cfg.MaxStale)
+			if until := now.Add(c.cfg.MaxStale); until.Before(limit) {
+				limit = until
+			}
+			if limit.Before(e.ExpiresAt) {
+				e.ExpiresAt = limit
+			}
+			n++
+		}
+	}
+	return n
+}
+
+// DeleteExpired removes expired entries and returns how many were removed
+func (c *Cache) DeleteExpired() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	n := 0
+	for _, el := range c.data {
+		if now.After(el.Value.(*entry).ExpiresAt) {
+			c.remove(el)
+			n++
+		}
+	}
+	c.expirations.Add(uint64(n))
+	return n
+}
+
+// Janitor removes expired entries every interval until ctx is done
+func (c *Cache) Janitor(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			c.DeleteExpired()
+		}
+	}
+}
+
+// Stats is a snapshot of the cache counters
+type Stats struct {
+	Entries     int    `json:"entries"`
+	Bytes       int64  `json:"bytes"`
+	MaxEntries  int    `json:"max_entries"`
+	MaxBytes    int64  `json:"max_bytes"`
+	Hits        uint64 `json:"hits"`
+	StaleHits   uint64 `json:"stale_hits"`
+	Misses      uint64 `json:"misses"`
+	Evictions   uint64 `json:"evictions"`
+	Expirations uint64 `json:"expirations"`
+}
+
+func (c *Cache) Stats() Stats {
+	c.mu.Lock()
+	entries, bytes := c.lru.Len(), c.bytes
+	c.mu.Unlock()
+	return Stats{
+		Entries:     entries,
+		Bytes:       bytes,
+		MaxEntries:  c.cfg.MaxEntries,
+		MaxBytes:    c.cfg.MaxBytes,
+		Hits:        c.hits.Load(),
+		StaleHits:   c.staleHits.Load(),
+		Misses:      c.misses.Load(),
+		Evictions:   c.evictions.Load(),
+		Expirations: c.expirations.Load(),
+	}
+}
+
+// EntryInfo describes one cached entry for the admin listing
+type EntryInfo struct {
+	Key        string    `json:"key"`
+	Size       int       `json:"size"`
+	Age        float64   `json:"age_seconds"`
+	State      string    `json:"state"`
+	FreshUntil time.Time `json:"fresh_until"`
+	StaleUntil time.Time `json:"stale_until"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// Entries lists the entries whose key starts with prefix, sorted by key
+func (c *Cache) Entries(prefix string) []EntryInfo {
+	now := time.Now()
+	c.mu.Lock()
+	out := make([]EntryInfo, 0, len(c.data))
+	for k, el := range c.data {
+		if !strings.HasPrefix(k, prefix) {
+			continue
+		}
+		e := el.Value.(*entry)
+		out = append(out, EntryInfo{
+			Key:        k,
+			Size:       len(e.Data),
+			Age:        now.Sub(e.CreatedAt).Seconds(),
+			State:      e.state(now).String(),
+			FreshUntil: e.FreshUntil,
+			StaleUntil: e.StaleUntil,
+			ExpiresAt:  e.ExpiresAt,
+		})
+	}
 	c.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out
 }
@@ -0,0 +1,29 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+// TestExpireHonoursMaxStale checks that an expired entry is only kept as a
+// stale-if-error fallback when MaxStale allows it
+func TestExpireHonoursMaxStale(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		maxStale time.Duration
+		want     State
+	}{
+		{"max stale 0", 0, Miss},
+		{"max stale 1h", time.Hour, StaleIfError},
+	} {
+		c := NewBounded(Config{TTL: time.Minute, StaleTTL: 5 * time.Minute, MaxStale: tc.maxStale})
+		c.Set("kpi_provinsi:default:1", []byte("x"))
+		if n := c.Expire("kpi_provinsi:"); n != 1 {
+			t.Fatalf("%s: Expire = %d, want 1", tc.name, n)
+		}
+		time.Sleep(time.Millisecond)
+		if _, st := c.Lookup("kpi_provinsi:default:1"); st != tc.want {
+			t.Errorf("%s: state after Expire = %v, want %v", tc.name, st, tc.want)
+		}
+	}
+}
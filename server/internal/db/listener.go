@@ -0,0 +1,99 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// NotifyTables are the Plane tables whose changes are announced by the
+// notify triggers
+var NotifyTables = []string{"issues", "issue_assignees", "issue_labels", "issue_comments"}
+
+// notifyTrigger is the trigger (and function) name installed by InstallTriggers
+const notifyTrigger = "serumpun_notify_change"
+
+var channelPattern = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+// ValidChannel reports whether name can be used as a LISTEN channel
+func ValidChannel(name string) bool {
+	return channelPattern.MatchString(name)
+}
+
+// InstallTriggers runs the notify trigger script (queries/notify_triggers.sql)
+// with {{CHANNEL}} set to channel.
+func InstallTriggers(ctx context.Context, pool *pgxpool.Pool, script, channel string) error {
+	if !ValidChannel(channel) {
+		return fmt.Errorf("invalid channel name %q", channel)
+	}
+	sql := strings.ReplaceAll(script, "{{CHANNEL}}", "'"+channel+"'")
+	// no arguments: simple protocol, so the script may hold several statements
+	_, err := pool.Exec(ctx, sql)
+	return err
+}
+
+// TriggersInstalled returns how many of NotifyTables carry the notify trigger
+func TriggersInstalled(ctx context.Context, pool *pgxpool.Pool) (int, error) {
+	var n int
+	err := pool.QueryRow(ctx, `
+		SELECT COUNT(DISTINCT c.relname)
+		FROM pg_trigger t
+		JOIN pg_class c ON c.oid = t.tgrelid
+		WHERE t.tgname = $1
+		  AND NOT t.tgisinternal
+		  AND c.relname = ANY($2::text[])`, notifyTrigger, NotifyTables).Scan(&n)
+	return n, err
+}
+
+// Listen holds a dedicated connection (outside the pool, so it never takes
+// one of the 5 query connections) that LISTENs on channel and calls handle
+// with every payload. onState is told about (re)connects and failures.
+// It reconnects with backoff until ctx is done.
+func Listen(ctx context.Context, databaseURL, channel string, handle func(payload string), onState func(connected bool, err error)) {
+	backoff := time.Second
+	for ctx.Err() == nil {
+		err := listenOnce(ctx, databaseURL, channel, handle, func() {
+			backoff = time.Second
+			onState(true, nil)
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		onState(false, err)
+		log.Printf("notify listener: %v (retry in %s)", err, backoff)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, time.Minute)
+	}
+}
+
+func listenOnce(ctx context.Context, databaseURL, channel string, handle func(string), connected func()) error {
+	conn, err := pgx.Connect(ctx, databaseURL)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+		return err
+	}
+	connected()
+
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		handle(n.Payload)
+	}
+}
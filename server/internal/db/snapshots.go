@@ -0,0 +1,112 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Snapshot is one report result stored for a day
+type Snapshot struct {
+	Date        time.Time
+	WorkspaceID string
+	ProjectID   string
+	Report      string
+	Keys        []string          // row keys, parallel to Rows
+	Rows        []json.RawMessage // one JSON object per report row
+}
+
+// EnsureSnapshotSchema runs the snapshot schema script
+// (queries/snapshot_schema.sql); it is idempotent.
+func EnsureSnapshotSchema(ctx context.Context, pool *pgxpool.Pool, script string) error {
+	// no arguments: simple protocol, so the script may hold several statements
+	_, err := pool.Exec(ctx, script)
+	return err
+}
+
+// SaveSnapshot replaces the snapshot of a report for a day
+func SaveSnapshot(ctx context.Context, pool *pgxpool.Pool, snap Snapshot) error {
+	return pgx.BeginFunc(ctx, pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `
+			DELETE FROM serumpun.kpi_snapshots
+			WHERE project_id = $1::uuid AND report = $2 AND snapshot_date = $3::date`,
+			snap.ProjectID, snap.Report, snap.Date.Format(time.DateOnly)); err != nil {
+			return err
+		}
+		rows := make([]string, len(snap.Rows))
+		for i, r := range snap.Rows {
+			rows[i] = string(r)
+		}
+		_, err := tx.Exec(ctx, `
+			INSERT INTO serumpun.kpi_snapshots (snapshot_date, workspace_id, project_id, report, row_key, data)
+			SELECT $1::date, $2::uuid, $3::uuid, $4, k, d::jsonb
+			FROM unnest($5::text[], $6::text[]) AS t(k, d)`,
+			snap.Date.Format(time.DateOnly), snap.WorkspaceID, snap.ProjectID, snap.Report, snap.Keys, rows)
+		return err
+	})
+}
+
+// SnapshotTaken reports whether every report in reports has a snapshot for date
+func SnapshotTaken(ctx context.Context, pool *pgxpool.Pool, projectID string, reports []string, date time.Time) (bool, error) {
+	var n int
+	err := pool.QueryRow(ctx, `
+		SELECT COUNT(DISTINCT report)
+		FROM serumpun.kpi_snapshots
+		WHERE project_id = $1::uuid AND snapshot_date = $2::date AND report = ANY($3::text[])`,
+		projectID, date.Format(time.DateOnly), reports).Scan(&n)
+	if isUndefinedTable(err) {
+		return false, nil
+	}
+	return n == len(reports), err
+}
+
+// LoadSnapshot returns the latest snapshot of a report taken on or before
+// date, keyed by row key. Without any (or without the snapshot table) it
+// returns a zero date and no rows.
+func LoadSnapshot(ctx context.Context, pool *pgxpool.Pool, projectID, report string, date time.Time) (time.Time, map[string]json.RawMessage, error) {
+	rows, err := pool.Query(ctx, `
+		SELECT snapshot_date, row_key, data
+		FROM serumpun.kpi_snapshots
+		WHERE project_id = $1::uuid
+		  AND report = $2
+		  AND snapshot_date = (
+		    SELECT MAX(snapshot_date)
+		    FROM serumpun.kpi_snapshots
+		    WHERE project_id = $1::uuid AND report = $2 AND snapshot_date <= $3::date
+		  )`,
+		projectID, report, date.Format(time.DateOnly))
+	if isUndefinedTable(err) {
+		return time.Time{}, nil, nil
+	}
+	if err != nil {
+		return time.Time{}, nil, err
+	}
+	defer rows.Close()
+
+	var taken time.Time
+	out := map[string]json.RawMessage{}
+	for rows.Next() {
+		var key string
+		var data []byte
+		if err := rows.Scan(&taken, &key, &data); err != nil {
+			return time.Time{}, nil, err
+		}
+		out[key] = data
+	}
+	if isUndefinedTable(rows.Err()) {
+		return time.Time{}, nil, nil
+	}
+	return taken, out, rows.Err()
+}
+
+// isUndefinedTable reports whether err is "relation does not exist", i.e.
+// snapshots were never enabled
+func isUndefinedTable(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "42P01"
+}
@@ -0,0 +1,115 @@
+// Package forecast estimates completion dates by resampling historical
+// weekly throughput (Monte Carlo simulation).
+package forecast
+
+import (
+	"hash/fnv"
+	"math/rand/v2"
+	"sort"
+	"time"
+)
+
+// MaxWeeks bounds one simulated trial; trials that do not finish by then
+// count as never finishing
+const MaxWeeks = 520
+
+// Input is the history and backlog of one group (bidang, kab/kota, ...)
+type Input struct {
+	Key       string // seeds the random source, so a result is reproducible
+	Weekly    []int  // completed issues per week, any order
+	Remaining int    // open issues
+	Target    time.Time
+}
+
+// Result is the outcome of Simulate. Dates are zero when the group cannot
+// finish within MaxWeeks at the given percentile (e.g. no throughput at all).
+type Result struct {
+	P50, P85, P95 time.Time
+	// ProbByTarget is the share of trials finishing on or before Target,
+	// -1 when Target is zero
+	ProbByTarget float64
+}
+
+// Simulate runs trials of drawing a random historical week until the
+// remaining issues are done. Each simulated week ends 7 days after the
+// previous one, starting from today.
+func Simulate(in Input, trials int, today time.Time) Result {
+	res := Result{ProbByTarget: -1}
+	if in.Remaining <= 0 {
+		res.P50, res.P85, res.P95 = today, today, today
+		if !in.Target.IsZero() {
+			res.ProbByTarget = 1
+		}
+		return res
+	}
+
+	weeks := simulateWeeks(in, trials)
+	res.P50 = weekDate(today, percentile(weeks, 0.50))
+	res.P85 = weekDate(today, percentile(weeks, 0.85))
+	res.P95 = weekDate(today, percentile(weeks, 0.95))
+
+	if !in.Target.IsZero() {
+		hits := 0
+		for _, w := range weeks {
+			if w <= MaxWeeks && !weekDate(today, w).After(in.Target) {
+				hits++
+			}
+		}
+		res.ProbByTarget = float64(hits) / float64(len(weeks))
+	}
+	return res
+}
+
+// simulateWeeks returns the sorted number of weeks each trial needed;
+// MaxWeeks+1 means the trial did not finish
+func simulateWeeks(in Input, trials int) []int {
+	weeks := make([]int, trials)
+	if !hasThroughput(in.Weekly) {
+		for i := range weeks {
+			weeks[i] = MaxWeeks + 1
+		}
+		return weeks
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(in.Key))
+	rng := rand.New(rand.NewPCG(h.Sum64(), uint64(in.Remaining)))
+
+	for i := range weeks {
+		done, w := 0, 0
+		for done < in.Remaining && w <= MaxWeeks {
+			done += in.Weekly[rng.IntN(len(in.Weekly))]
+			w++
+		}
+		weeks[i] = w
+	}
+	sort.Ints(weeks)
+	return weeks
+}
+
+func hasThroughput(weekly []int) bool {
+	for _, n := range weekly {
+		if n > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// percentile is the nearest-rank percentile of sorted values
+func percentile(sorted []int, p float64) int {
+	if len(sorted) == 0 {
+		return MaxWeeks + 1
+	}
+	i := int(p*float64(len(sorted))+0.5) - 1
+	i = max(0, min(i, len(sorted)-1))
+	return sorted[i]
+}
+
+// weekDate is the end of simulated week w, or zero when it never finishes
+func weekDate(today time.Time, w int) time.Time {
+	if w > MaxWeeks {
+		return time.Time{}
+	}
+	return today.AddDate(0, 0, 7*w)
+}
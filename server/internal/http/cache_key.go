@@ -0,0 +1,84 @@
+package httpx
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"serumpun-data-api/internal/plane"
+)
+
+// cacheKeyInput is everything a cached report depends on
+type cacheKeyInput struct {
+	Endpoint  string
+	Project   plane.Project
+	Templates []string          // SQL template files the report is rendered from
+	Params    map[string]string // filters and output options (format, locale, ...)
+}
+
+// reportCacheKey builds the canonical cache key of a report: the endpoint
+// name and project key followed by a hash of the project ids, the directory version, the
+// checksum of every SQL template and the normalized parameters. Parameter
+// names are lower-cased and empty values are dropped, so "?bidang=" and no
+// parameter share a key while "?bidang=X" and "?jabatan=X" never do.
+// The readable "endpoint:project:" prefix keeps DeletePrefix invalidation
+// working per endpoint and per project.
+func (s *Server) reportCacheKey(in cacheKeyInput) (string, error) {
+	var b strings.Builder
+	line := func(k, v string) {
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(strconv.Quote(v))
+		b.WriteByte('\n')
+	}
+
+	line("endpoint", in.Endpoint)
+	line("workspace", in.Project.WorkspaceID)
+	line("project", in.Project.ProjectID)
+	line("directory", s.Directory.Current().Version)
+
+	templates := append([]string(nil), in.Templates...)
+	sort.Strings(templates)
+	for _, name := range templates {
+		sum, err := s.Queries.Checksum(name)
+		if err != nil {
+			return "", errorStatus(http.StatusInternalServerError, "failed to load sql template: %v", err)
+		}
+		line("template:"+name, sum)
+	}
+
+	params := make(map[string]string, len(in.Params))
+	for k, v := range in.Params {
+		k = strings.ToLower(strings.TrimSpace(k))
+		if v = strings.TrimSpace(v); k != "" && v != "" {
+			params[k] = v
+		}
+	}
+	names := make([]string, 0, len(params))
+	for k := range params {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		line("param:"+k, params[k])
+	}
+
+	sum := sha256.Sum256([]byte(b.String()))
+	return projectCachePrefix(in.Endpoint, in.Project) + hex.EncodeToString(sum[:16]), nil
+}
+
+// endpointCachePrefix is the key prefix of an endpoint's entries. Endpoint
+// names contain "_", so ":" separates them from the project key.
+func endpointCachePrefix(endpoint string) string {
+	return endpoint + ":"
+}
+
+// projectCachePrefix is the key prefix of an endpoint's entries for a
+// project. Project keys cannot contain ":" (see plane.NewRegistry), so the
+// prefix of one project never matches another's keys.
+func projectCachePrefix(endpoint string, project plane.Project) string {
+	return endpointCachePrefix(endpoint) + project.Key + ":"
+}
@@ -0,0 +1,57 @@
+package httpx
+
+import (
+	"testing"
+	"time"
+
+	"serumpun-data-api/internal/cache"
+	"serumpun-data-api/internal/plane"
+	"serumpun-data-api/internal/queries"
+)
+
+// TestProjectCachePrefixIsolation checks that invalidating one project never
+// touches a project whose key extends it ("default" vs "default_x")
+func TestProjectCachePrefixIsolation(t *testing.T) {
+	dir, err := NewDirectory(directoryPath, loadTestRules(t), loadTestRegions(t), PolicyContinue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{Cache: cache.New(time.Minute), Queries: queries.New("../../queries"), Directory: dir}
+
+	a := plane.Project{Key: "default", WorkspaceID: "58f6ec9b-f0ae-4e68-8f05-8f1d9ddf9cac", ProjectID: "cfc12151-e169-4caf-bca9-3eb83ed588ee"}
+	b := plane.Project{Key: "default_x", WorkspaceID: "58f6ec9b-f0ae-4e68-8f05-8f1d9ddf9cac", ProjectID: "0b6a5b36-3f5e-4c1a-9d0e-7a1f2c3d4e5f"}
+	if _, err := plane.NewRegistry([]plane.Project{a, b}, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	key := func(p plane.Project) string {
+		k, err := s.reportCacheKey(cacheKeyInput{Endpoint: "kpi_provinsi", Project: p, Templates: []string{"kpi_provinsi.sql"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return k
+	}
+	keyA, keyB := key(a), key(b)
+	s.Cache.Set(keyA, []byte("a"))
+	s.Cache.Set(keyB, []byte("b"))
+
+	if n := s.Cache.Expire(projectCachePrefix("kpi_provinsi", a)); n != 1 {
+		t.Errorf("Expire(default) expired %d entries, want 1", n)
+	}
+	if _, st := s.Cache.Lookup(keyB); st != cache.Fresh {
+		t.Errorf("default_x entry is %v after expiring default, want fresh", st)
+	}
+	if n := s.Cache.DeletePrefix(projectCachePrefix("kpi_provinsi", a)); n != 1 {
+		t.Errorf("DeletePrefix(default) removed %d entries, want 1", n)
+	}
+	if _, st := s.Cache.Lookup(keyB); st != cache.Fresh {
+		t.Errorf("default_x entry is %v after deleting default, want fresh", st)
+	}
+
+	// Keys that could break the separator are rejected
+	bad := a
+	bad.Key = "default:x"
+	if _, err := plane.NewRegistry([]plane.Project{bad}, ""); err == nil {
+		t.Errorf("NewRegistry accepted project key %q", bad.Key)
+	}
+}
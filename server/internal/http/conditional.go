@@ -0,0 +1,127 @@
+package httpx
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"serumpun-data-api/internal/plane"
+)
+
+// defaultMaxAge is the Cache-Control max-age when none is configured
+const defaultMaxAge = 30
+
+// strongETag is a strong validator for a response body
+func strongETag(b []byte) string {
+	sum := sha256.Sum256(b)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// writeBody writes a rendered response with Cache-Control, ETag and
+// Last-Modified. http.ServeContent answers If-None-Match/If-Modified-Since
+// with 304 and handles HEAD and Range requests. Content-Type must be set.
+func (s *Server) writeBody(w http.ResponseWriter, r *http.Request, endpoint string, body []byte, etag string, modified time.Time) {
+	w.Header().Set("Cache-Control", s.cacheControl(endpoint))
+	w.Header().Set("ETag", etag)
+	http.ServeContent(w, r, "", modified, bytes.NewReader(body))
+}
+
+// cacheControl is the Cache-Control header for an endpoint
+func (s *Server) cacheControl(endpoint string) string {
+	maxAge := s.MaxAge
+	if maxAge <= 0 {
+		maxAge = defaultMaxAge
+	}
+	if n, ok := s.EndpointMaxAge[endpoint]; ok {
+		maxAge = n
+	}
+	return "public, max-age=" + strconv.Itoa(maxAge)
+}
+
+// ParseMaxAges parses per-endpoint freshness lifetimes in the form
+// "timeline=60,report=300" (seconds)
+func ParseMaxAges(s string) (map[string]int, error) {
+	out := map[string]int{}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, val, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid entry %q (want endpoint=seconds)", part)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(val))
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid max-age for %s: %q", name, val)
+		}
+		out[strings.TrimSpace(name)] = n
+	}
+	return out, nil
+}
+
+// lastModifiedCacheName is the cache key prefix of the per-project newest
+// change time that reportModified looks up
+const lastModifiedCacheName = "last_modified"
+
+// reportModified is the Last-Modified of a report: the newest
+// issues.updated_at/issue_comments.created_at in the project, raised to the
+// directory file time and to the start of today, since names come from the
+// directory and deadline statuses change with CURRENT_DATE. A failed lookup
+// is logged and yields the zero time (no Last-Modified).
+func (s *Server) reportModified(ctx context.Context, project plane.Project) time.Time {
+	modified, ok := s.projectNewest(ctx, project)
+	if !ok {
+		return time.Time{}
+	}
+	if dir := s.Directory.Current(); dir.ModTime.After(modified) {
+		modified = dir.ModTime
+	}
+	now := time.Now().In(s.CSV.location())
+	if today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()); today.After(modified) {
+		modified = today
+	}
+	return modified.Truncate(time.Second)
+}
+
+// projectNewest is the newest issue or comment change in the project. It is
+// cached like a report (and expired with them by ChangeNotifier), so report
+// misses within the TTL do not query it again. A project without issues
+// yields the zero time.
+func (s *Server) projectNewest(ctx context.Context, project plane.Project) (time.Time, bool) {
+	key := projectCachePrefix(lastModifiedCacheName, project)
+	if b, ok := s.Cache.Get(key); ok {
+		var t time.Time
+		if err := t.UnmarshalText(b); err == nil {
+			return t, true
+		}
+	}
+
+	sqlText, err := s.Queries.Load("last_modified.sql")
+	if err != nil {
+		log.Printf("last modified: %v", err)
+		return time.Time{}, false
+	}
+
+	var newest *time.Time
+	if err := s.DB.QueryRow(ctx, sqlText, project.WorkspaceID, project.ProjectID).Scan(&newest); err != nil {
+		log.Printf("last modified (%s): %v", project.Key, err)
+		return time.Time{}, false
+	}
+
+	var t time.Time
+	if newest != nil {
+		t = *newest
+	}
+	if b, err := t.MarshalText(); err == nil {
+		s.Cache.Set(key, b)
+	}
+	return t, true
+}
@@ -4,15 +4,33 @@ import (
 	"bytes"
 	"context"
 	"encoding/csv"
-	"fmt"
+	"io"
+	"net/http"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-func QueryToCSV(ctx context.Context, pool *pgxpool.Pool, sql string, args ...any) ([]byte, error) {
+// streamFlushRows is how many rows StreamCSV writes between flushes
+const streamFlushRows = 500
+
+func QueryToCSV(ctx context.Context, pool *pgxpool.Pool, cf CSVFormat, sql string, args ...any) ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := StreamCSV(ctx, pool, &buf, cf, nil, nil, sql, args...); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// StreamCSV runs the query and writes CSV to out row by row as pgx returns
+// them. onStart is called once right before the first byte is written, so an
+// error before any row (bad SQL, timeout) can still be turned into a 500 by
+// the caller; started reports whether that point was reached. flush is called
+// every streamFlushRows rows. Both callbacks may be nil. Values are rendered
+// by the per-OID formatters in csv_format.go.
+func StreamCSV(ctx context.Context, pool *pgxpool.Pool, out io.Writer, cf CSVFormat, onStart, flush func(), sql string, args ...any) (started bool, err error) {
 	rows, err := pool.Query(ctx, sql, args...)
 	if err != nil {
-		return nil, err
+		return false, err
 	}
 	defer rows.Close()
 
@@ -22,38 +40,130 @@ func QueryToCSV(ctx context.Context, pool *pgxpool.Pool, sql string, args ...any
 		headers[i] = string(f.Name)
 	}
 
-	var buf bytes.Buffer
-	w := csv.NewWriter(&buf)
+	w := csv.NewWriter(out)
+	w.Comma = cf.Comma()
 
-	if err := w.Write(headers); err != nil {
-		return nil, err
+	begin := func() error {
+		if started {
+			return nil
+		}
+		started = true
+		if onStart != nil {
+			onStart()
+		}
+		return w.Write(headers)
 	}
 
+	n := 0
 	for rows.Next() {
 		vals, err := rows.Values()
 		if err != nil {
-			return nil, err
+			return started, err
+		}
+		if err := begin(); err != nil {
+			return started, err
 		}
 		rec := make([]string, len(vals))
 		for i, v := range vals {
-			if v == nil {
-				rec[i] = ""
-				continue
-			}
-			rec[i] = fmt.Sprint(v)
+			rec[i] = cf.formatCSVValue(v, fields[i].DataTypeOID)
 		}
 		if err := w.Write(rec); err != nil {
-			return nil, err
+			return started, err
+		}
+
+		n++
+		if n%streamFlushRows == 0 {
+			w.Flush()
+			if err := w.Error(); err != nil {
+				return started, err
+			}
+			if flush != nil {
+				flush()
+			}
 		}
 	}
 
 	if rows.Err() != nil {
-		return nil, rows.Err()
+		return started, rows.Err()
+	}
+
+	// empty result still gets the header line
+	if err := begin(); err != nil {
+		return started, err
 	}
 
 	w.Flush()
-	if err := w.Error(); err != nil {
+	return started, w.Error()
+}
+
+// TableToCSV renders an in-memory result with the same formatting as StreamCSV
+func TableToCSV(t *resultTable, cf CSVFormat) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Comma = cf.Comma()
+	if err := w.Write(t.Columns); err != nil {
 		return nil, err
 	}
-	return buf.Bytes(), nil
+	for _, vals := range t.Rows {
+		rec := make([]string, len(vals))
+		for i, v := range vals {
+			rec[i] = cf.formatCSVValue(v, t.OIDs[i])
+		}
+		if err := w.Write(rec); err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// cacheBuffer keeps a streamed result for the cache, up to limit bytes.
+// Past the limit it drops what it holds and only records the overflow, so a
+// large export never sits in memory as a whole.
+type cacheBuffer struct {
+	limit    int
+	data     []byte
+	Overflow bool
+}
+
+func (b *cacheBuffer) Write(p []byte) (int, error) {
+	if b.Overflow {
+		return len(p), nil
+	}
+	if len(b.data)+len(p) > b.limit {
+		b.Overflow, b.data = true, nil
+		return len(p), nil
+	}
+	b.data = append(b.data, p...)
+	return len(p), nil
+}
+
+// Bytes returns the buffered result; nil after an overflow
+func (b *cacheBuffer) Bytes() []byte { return b.data }
+
+// clientWriter writes a stream to one client. Once the client has gone away
+// writes are dropped instead of failing, so the read goes on and the result
+// can still be cached and shared.
+type clientWriter struct {
+	w   http.ResponseWriter
+	rc  *http.ResponseController
+	err error
+}
+
+func newClientWriter(w http.ResponseWriter) *clientWriter {
+	return &clientWriter{w: w, rc: http.NewResponseController(w)}
+}
+
+func (c *clientWriter) Write(p []byte) (int, error) {
+	if c.err == nil {
+		_, c.err = c.w.Write(p)
+	}
+	return len(p), nil
+}
+
+// flush pushes what was written so far to the client
+func (c *clientWriter) flush() {
+	if c.err == nil {
+		_ = c.rc.Flush()
+	}
 }
@@ -0,0 +1,187 @@
+package httpx
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// CSV locales
+const (
+	LocaleDefault = ""   // "." decimal point, "," delimiter
+	LocaleID      = "id" // "," decimal comma, ";" delimiter (Excel Indonesia)
+)
+
+// DefaultCSVTimezone is used for timestamps when CSV_TIMEZONE is not set
+var DefaultCSVTimezone = time.FixedZone("WIB", 7*60*60)
+
+// CSVFormat controls how StreamCSV renders values
+type CSVFormat struct {
+	Location  *time.Location // timezone for timestamps (nil = WIB)
+	Precision int            // digits after the decimal point for numeric/float
+	Locale    string         // LocaleDefault or LocaleID
+}
+
+// DefaultCSVFormat is WIB timestamps, 2 decimals and the default locale
+func DefaultCSVFormat() CSVFormat {
+	return CSVFormat{Location: DefaultCSVTimezone, Precision: 2}
+}
+
+// ParseLocale validates a locale name ("", "en" or "id")
+func ParseLocale(s string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "en", "default":
+		return LocaleDefault, nil
+	case "id", "id-id":
+		return LocaleID, nil
+	}
+	return "", fmt.Errorf("unsupported locale %q (supported: en, id)", s)
+}
+
+// WithLocale returns a copy of f using locale
+func (f CSVFormat) WithLocale(locale string) CSVFormat {
+	f.Locale = locale
+	return f
+}
+
+// Comma is the field delimiter for the locale
+func (f CSVFormat) Comma() rune {
+	if f.Locale == LocaleID {
+		return ';'
+	}
+	return ','
+}
+
+// location returns the timestamp timezone
+func (f CSVFormat) location() *time.Location {
+	if f.Location == nil {
+		return DefaultCSVTimezone
+	}
+	return f.Location
+}
+
+// decimal applies the locale's decimal separator to a formatted number
+func (f CSVFormat) decimal(s string) string {
+	if f.Locale == LocaleID {
+		return strings.Replace(s, ".", ",", 1)
+	}
+	return s
+}
+
+// csvFormatter renders one non-nil value of a given column type
+type csvFormatter func(f CSVFormat, v any) string
+
+// csvFormatters is the per-OID formatter registry. Types not listed here
+// fall back to formatCSVAny, which still recognises the common pgx types.
+var csvFormatters = map[uint32]csvFormatter{
+	pgtype.DateOID:        formatCSVDate,
+	pgtype.TimestampOID:   formatCSVTimestamp,
+	pgtype.TimestamptzOID: formatCSVTimestamp,
+	pgtype.NumericOID:     formatCSVNumber,
+	pgtype.Float4OID:      formatCSVNumber,
+	pgtype.Float8OID:      formatCSVNumber,
+	pgtype.UUIDOID:        formatCSVUUID,
+}
+
+// formatCSVValue renders v for a column of type oid; NULL is an empty field
+func (f CSVFormat) formatCSVValue(v any, oid uint32) string {
+	if v == nil {
+		return ""
+	}
+	if fn, ok := csvFormatters[oid]; ok {
+		return fn(f, v)
+	}
+	return formatCSVAny(f, v)
+}
+
+// formatCSVDate writes dates as YYYY-MM-DD
+func formatCSVDate(f CSVFormat, v any) string {
+	if t, ok := v.(time.Time); ok {
+		return t.Format("2006-01-02")
+	}
+	return formatCSVAny(f, v)
+}
+
+// formatCSVTimestamp writes timestamps as RFC3339 in the configured timezone.
+// pgx decodes timestamp without time zone as UTC, so those are treated as UTC.
+func formatCSVTimestamp(f CSVFormat, v any) string {
+	if t, ok := v.(time.Time); ok {
+		return t.In(f.location()).Format(time.RFC3339)
+	}
+	return formatCSVAny(f, v)
+}
+
+// formatCSVNumber writes numeric and float values at a fixed precision.
+// NaN and infinities become empty fields.
+func formatCSVNumber(f CSVFormat, v any) string {
+	switch x := v.(type) {
+	case pgtype.Numeric:
+		if !x.Valid || x.NaN || x.InfinityModifier != pgtype.Finite {
+			return ""
+		}
+		return f.decimal(numericString(x, f.Precision))
+	case float64:
+		if math.IsNaN(x) || math.IsInf(x, 0) {
+			return ""
+		}
+		return f.decimal(strconv.FormatFloat(x, 'f', f.Precision, 64))
+	case float32:
+		return formatCSVNumber(f, float64(x))
+	}
+	return formatCSVAny(f, v)
+}
+
+// formatCSVUUID writes UUIDs in canonical form
+func formatCSVUUID(f CSVFormat, v any) string {
+	if u, ok := v.([16]byte); ok {
+		return formatUUID(u)
+	}
+	return formatCSVAny(f, v)
+}
+
+// formatCSVAny is the fallback for OIDs without a registered formatter
+// (e.g. expressions of unknown type) and for unexpected Go types.
+func formatCSVAny(f CSVFormat, v any) string {
+	switch x := v.(type) {
+	case string:
+		return x
+	case time.Time:
+		return x.In(f.location()).Format(time.RFC3339)
+	case pgtype.Numeric, float64, float32:
+		return formatCSVNumber(f, x)
+	case [16]byte:
+		return formatUUID(x)
+	case []byte:
+		return string(x)
+	}
+	return fmt.Sprint(v)
+}
+
+// numericString formats a finite numeric exactly, rounded to prec decimals
+func numericString(n pgtype.Numeric, prec int) string {
+	r := new(big.Rat)
+	if n.Int != nil {
+		r.SetInt(n.Int)
+	}
+	if n.Exp != 0 {
+		scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(absInt(int(n.Exp)))), nil)
+		if n.Exp > 0 {
+			r.Mul(r, new(big.Rat).SetInt(scale))
+		} else {
+			r.Quo(r, new(big.Rat).SetInt(scale))
+		}
+	}
+	return r.FloatString(prec)
+}
+
+func absInt(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -4,7 +4,6 @@ import (
 	"encoding/csv"
 	"fmt"
 	"io"
-	"os"
 	"strings"
 )
 
@@ -13,25 +12,50 @@ type DirectoryRow struct {
 	Nama     string
 	Instansi string
 	Scope    string // provinsi | kabkot
-	Jabatan  string // Ketua | Anggota
+	Jabatan  string // normalised by the role rules (Ketua, Anggota, Pengarah, ...)
 	Bidang   string
+	Invited  bool // Invitation = "Sudah"
+	Joined   bool // Join Proyek = "Sudah"
+}
+
+// DirectoryMember is one CSV row as written, before the role rules decide
+// whether it becomes a provinsi/kabkot row. It feeds the audit reports.
+type DirectoryMember struct {
+	Line       int // line in the CSV file
+	Nama       string
+	RawEmail   string // "Akun Gmail" exactly as written
+	Email      string // normalised (trimmed, lower case)
+	Instansi   string
+	Jabatan    string
+	Bidang     string // from the role rules, "-" when no rule matches
+	Invitation string // "Sudah" / "Belum" as written, "" when the column is absent
+	JoinProyek string
+	Invited    bool
+	Joined     bool
 }
 
 type DirectoryResult struct {
 	Provinsi   []DirectoryRow
 	Kabkot     []DirectoryRow
 	BidangList []string
+	Members    []DirectoryMember // every row with an email, in file order
+	Issues     []DirectoryIssue  // per-line validation findings, in file order
 }
 
-func LoadDirectoryFromCSV(path string) (DirectoryResult, error) {
-	f, err := os.Open(path)
-	if err != nil {
-		return DirectoryResult{}, fmt.Errorf("open directory csv: %w", err)
-	}
-	defer f.Close()
+// All returns provinsi and kabkot rows in a newly allocated slice, so callers
+// may append to it without touching the shared directory.
+func (d DirectoryResult) All() []DirectoryRow {
+	out := make([]DirectoryRow, 0, len(d.Provinsi)+len(d.Kabkot))
+	out = append(out, d.Provinsi...)
+	return append(out, d.Kabkot...)
+}
 
-	r := csv.NewReader(f)
+// parseDirectoryCSV parses the staff directory from any reader, classifying
+// jabatan with rules and checking kab/kota offices against regions
+func parseDirectoryCSV(in io.Reader, rules *RoleRules, regions *RegionRegistry) (DirectoryResult, error) {
+	r := csv.NewReader(in)
 	r.TrimLeadingSpace = true
+	r.FieldsPerRecord = -1 // row width is checked per line (column_shift)
 
 	header, err := r.Read()
 	if err != nil {
@@ -51,9 +75,23 @@ func LoadDirectoryFromCSV(path string) (DirectoryResult, error) {
 
 	type rawRow struct {
 		nama, email, instansi, jabatan string
+		invited, joined                bool
+	}
+
+	// field returns a column value, "" when the column or the cell is missing
+	field := func(rec []string, name string) string {
+		if i, ok := col[name]; ok && i < len(rec) {
+			return rec[i]
+		}
+		return ""
+	}
+	optional := func(rec []string, name string) string {
+		return strings.TrimSpace(field(rec, name))
 	}
 
 	var raws []rawRow
+	var members []DirectoryMember
+	v := newDirectoryValidator(header, rules, regions)
 	for {
 		rec, err := r.Read()
 		if err == io.EOF {
@@ -62,24 +100,42 @@ func LoadDirectoryFromCSV(path string) (DirectoryResult, error) {
 		if err != nil {
 			return DirectoryResult{}, fmt.Errorf("read row: %w", err)
 		}
+		line, _ := r.FieldPos(0)
+
+		nama := optional(rec, "Nama")
+		email := normEmail(field(rec, "Akun Gmail"))
+		inst := optional(rec, "Asal Instansi")
+		jab := optional(rec, "Jabatan Dalam Tim SE2026")
+		invitation := optional(rec, "Invitation")
+		joinProyek := optional(rec, "Join Proyek")
+		if !v.checkRow(line, rec, nama, email, inst, jab) {
+			continue
+		}
 
-		nama := strings.TrimSpace(rec[col["Nama"]])
-		email := normEmail(rec[col["Akun Gmail"]])
-		inst := strings.TrimSpace(rec[col["Asal Instansi"]])
-		jab := strings.TrimSpace(rec[col["Jabatan Dalam Tim SE2026"]])
+		if email != "" {
+			members = append(members, DirectoryMember{
+				Line: line, Nama: nama, RawEmail: field(rec, "Akun Gmail"), Email: email,
+				Instansi: inst, Jabatan: jab, Bidang: memberBidang(rules, jab, deriveScope(inst)),
+				Invitation: invitation, JoinProyek: joinProyek,
+				Invited: isSudah(invitation), Joined: isSudah(joinProyek),
+			})
+		}
 
 		if nama == "" || email == "" || inst == "" || jab == "" {
 			continue
 		}
 
-		raws = append(raws, rawRow{nama: nama, email: email, instansi: inst, jabatan: jab})
+		raws = append(raws, rawRow{
+			nama: nama, email: email, instansi: inst, jabatan: jab,
+			invited: isSudah(invitation), joined: isSudah(joinProyek),
+		})
 	}
 
-	// 1) derive bidang list from "Ketua/Anggota Bidang ..."
+	// 1) derive bidang list from jabatan naming a bidang ("Ketua/Anggota Bidang ...")
 	bidangSet := map[string]struct{}{}
 	for _, rr := range raws {
-		if b, ok := parseBidang(rr.jabatan); ok {
-			bidangSet[b] = struct{}{}
+		if role, ok := rules.Classify(rr.jabatan, deriveScope(rr.instansi)); ok && role.BidangFromJabatan {
+			bidangSet[role.Bidang] = struct{}{}
 		}
 	}
 	bidangList := make([]string, 0, len(bidangSet))
@@ -87,66 +143,41 @@ func LoadDirectoryFromCSV(path string) (DirectoryResult, error) {
 		bidangList = append(bidangList, b)
 	}
 
-	// 2) build provinsi rows (ALL staff, not just Ketua/Anggota Bidang)
+	// 2) build provinsi rows (ALL staff matching a role rule)
 	var prov []DirectoryRow
 	for _, rr := range raws {
-		scope := deriveScope(rr.instansi)
-		if scope != "provinsi" {
+		if deriveScope(rr.instansi) != "provinsi" {
 			continue
 		}
-
-		// Parse jabatan and bidang
-		jab, bidang, isBidang := parseJabatanBidang(rr.jabatan)
-
-		if isBidang {
-			// Ketua/Anggota Bidang
-			prov = append(prov, DirectoryRow{
-				Email: rr.email, Nama: rr.nama, Instansi: rr.instansi,
-				Scope: "provinsi", Jabatan: jab, Bidang: bidang,
-			})
-		} else {
-			// Other roles: Pengarah, Ketua Pelaksana, Ketua Sekretariat, etc.
-			// Map to appropriate bidang or mark as "Umum"
-			jabNorm, bidangNorm := parseOtherJabatan(rr.jabatan)
-			if jabNorm != "" {
-				prov = append(prov, DirectoryRow{
-					Email: rr.email, Nama: rr.nama, Instansi: rr.instansi,
-					Scope: "provinsi", Jabatan: jabNorm, Bidang: bidangNorm,
-				})
-			}
+		role, ok := rules.Classify(rr.jabatan, "provinsi")
+		if !ok {
+			continue
 		}
+		prov = append(prov, DirectoryRow{
+			Email: rr.email, Nama: rr.nama, Instansi: rr.instansi,
+			Scope: "provinsi", Jabatan: role.Jabatan, Bidang: role.Bidang,
+			Invited: rr.invited, Joined: rr.joined,
+		})
 	}
 
-	// 3) build kabkot rows (ALL Ketua: Kepala, Ketua Pelaksana, Ketua Sekretariat, Ketua Bidang)
+	// 3) build kabkot rows (level ketua: Kepala, Ketua Pelaksana, Sekretariat, Ketua Bidang)
 	var kab []DirectoryRow
 	for _, rr := range raws {
-		scope := deriveScope(rr.instansi)
-		if scope != "kabkot" {
+		if deriveScope(rr.instansi) != "kabkot" {
 			continue
 		}
-
-		// Check if it's Ketua Bidang
-		jab, bidang, isBidang := parseJabatanBidang(rr.jabatan)
-		if isBidang && jab == "Ketua" {
-			// Ketua Bidang X
-			kab = append(kab, DirectoryRow{
-				Email: rr.email, Nama: rr.nama, Instansi: rr.instansi,
-				Scope: "kabkot", Jabatan: "Ketua Bidang", Bidang: bidang,
-			})
+		role, ok := rules.Classify(rr.jabatan, "kabkot")
+		if !ok || role.Level != LevelKetua {
 			continue
 		}
-
-		// Check other Ketua roles (Kepala, Ketua Pelaksana, Ketua Sekretariat)
-		jabNorm, bidangNorm := parseOtherJabatan(rr.jabatan)
-		if jabNorm != "" && (strings.Contains(strings.ToLower(jabNorm), "ketua") || strings.Contains(strings.ToLower(jabNorm), "kepala")) {
-			kab = append(kab, DirectoryRow{
-				Email: rr.email, Nama: rr.nama, Instansi: rr.instansi,
-				Scope: "kabkot", Jabatan: jabNorm, Bidang: bidangNorm,
-			})
-		}
+		kab = append(kab, DirectoryRow{
+			Email: rr.email, Nama: rr.nama, Instansi: rr.instansi,
+			Scope: "kabkot", Jabatan: role.Jabatan, Bidang: role.Bidang,
+			Invited: rr.invited, Joined: rr.joined,
+		})
 	}
 
-	return DirectoryResult{Provinsi: prov, Kabkot: kab, BidangList: bidangList}, nil
+	return DirectoryResult{Provinsi: prov, Kabkot: kab, BidangList: bidangList, Members: members, Issues: v.issues}, nil
 }
 
 func indexHeader(h []string) map[string]int {
@@ -161,6 +192,11 @@ func normEmail(s string) string {
 	return strings.ToLower(strings.TrimSpace(s))
 }
 
+// isSudah reports whether an Invitation/Join Proyek cell says "Sudah"
+func isSudah(s string) bool {
+	return strings.EqualFold(strings.TrimSpace(s), "sudah")
+}
+
 func deriveScope(instansi string) string {
 	s := strings.ToLower(instansi)
 	switch {
@@ -177,42 +213,10 @@ func deriveScope(instansi string) string {
 	}
 }
 
-// "Ketua Bidang X" / "Anggota Bidang X"
-func parseBidang(jabatan string) (string, bool) {
-	_, b, ok := parseJabatanBidang(jabatan)
-	return b, ok
-}
-
-func parseJabatanBidang(jabatan string) (jabatanNorm, bidang string, ok bool) {
-	j := strings.TrimSpace(jabatan)
-	if strings.HasPrefix(j, "Ketua Bidang ") {
-		return "Ketua", strings.TrimSpace(strings.TrimPrefix(j, "Ketua Bidang ")), true
-	}
-	if strings.HasPrefix(j, "Anggota Bidang ") {
-		return "Anggota", strings.TrimSpace(strings.TrimPrefix(j, "Anggota Bidang ")), true
-	}
-	return "", "", false
-}
-
-// parseOtherJabatan handles non-bidang roles like Pengarah, Ketua Pelaksana, Sekretariat, Kepala
-func parseOtherJabatan(jabatan string) (jabatanNorm, bidang string) {
-	j := strings.TrimSpace(jabatan)
-	jLower := strings.ToLower(j)
-
-	switch {
-	case strings.Contains(jLower, "kepala kab/kot"):
-		return "Kepala Kab/Kot", "Umum"
-	case strings.Contains(jLower, "pengarah"):
-		return "Pengarah", "Umum"
-	case strings.Contains(jLower, "ketua pelaksana"):
-		return "Ketua Pelaksana", "Umum"
-	case strings.Contains(jLower, "ketua sekretariat"):
-		return "Ketua Sekretariat", "Sekretariat"
-	case strings.Contains(jLower, "wakil ketua sekretariat"):
-		return "Wakil Ketua Sekretariat", "Sekretariat"
-	case strings.Contains(jLower, "anggota sekretariat"):
-		return "Anggota Sekretariat", "Sekretariat"
-	default:
-		return "", ""
+// memberBidang is the bidang a jabatan belongs to, "-" when no rule matches
+func memberBidang(rules *RoleRules, jabatan, scope string) string {
+	if role, ok := rules.Classify(jabatan, scope); ok {
+		return role.Bidang
 	}
+	return "-"
 }
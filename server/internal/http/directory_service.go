@@ -0,0 +1,277 @@
+package httpx
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DirectorySnapshot is one parsed version of the staff directory CSV.
+// Snapshots are immutable once published; a reload swaps in a new one.
+type DirectorySnapshot struct {
+	DirectoryResult
+	Version  string    // content hash of the CSV file
+	ModTime  time.Time // mtime of the file when it was read
+	LoadedAt time.Time
+}
+
+// Directory holds the current staff directory and reloads it when the CSV
+// file changes on disk.
+type Directory struct {
+	path    string
+	current atomic.Pointer[DirectorySnapshot]
+
+	rules   *RoleRules
+	regions *RegionRegistry
+	policy  ValidationPolicy
+
+	mu         sync.Mutex
+	seenMod    time.Time // mtime of the last file loaded or rejected by policy
+	lastErr    error
+	validation *DirectoryValidation
+	onReload   []func(*DirectorySnapshot)
+}
+
+// DirectoryValidation is the validation result of the most recently read
+// version of the CSV file, whether or not the policy accepted it.
+type DirectoryValidation struct {
+	Version   string           `json:"version"`
+	CheckedAt time.Time        `json:"checked_at"`
+	Accepted  bool             `json:"accepted"`
+	Errors    int              `json:"errors"`
+	Warnings  int              `json:"warnings"`
+	Issues    []DirectoryIssue `json:"issues"`
+}
+
+// NewDirectory parses the directory at path, classifying jabatan with rules
+// and checking kab/kota offices against regions.
+// It fails if the initial file cannot be loaded or is rejected by policy,
+// since every KPI endpoint depends on it.
+func NewDirectory(path string, rules *RoleRules, regions *RegionRegistry, policy ValidationPolicy) (*Directory, error) {
+	d := &Directory{path: path, rules: rules, regions: regions, policy: policy}
+	snap, err := d.load()
+	if err != nil {
+		return nil, err
+	}
+	d.current.Store(snap)
+	d.seenMod = snap.ModTime
+	return d, nil
+}
+
+// Path returns the CSV file the directory is loaded from.
+func (d *Directory) Path() string {
+	return d.path
+}
+
+// Current returns the directory version in use.
+func (d *Directory) Current() *DirectorySnapshot {
+	return d.current.Load()
+}
+
+// Policy returns the validation policy applied to every load.
+func (d *Directory) Policy() ValidationPolicy {
+	return d.policy
+}
+
+// Validation returns the issues found in the most recently read file.
+func (d *Directory) Validation() *DirectoryValidation {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.validation
+}
+
+// LastError returns the error from the most recent failed reload, if any.
+func (d *Directory) LastError() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.lastErr
+}
+
+// OnReload registers fn to be called after a new version has been swapped in.
+func (d *Directory) OnReload(fn func(*DirectorySnapshot)) {
+	d.mu.Lock()
+	d.onReload = append(d.onReload, fn)
+	d.mu.Unlock()
+}
+
+// Watch polls the file mtime every interval until ctx is done and reloads
+// the directory when it changes.
+func (d *Directory) Watch(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if _, err := d.ReloadIfChanged(); err != nil {
+				log.Printf("directory reload failed, keeping version %s: %v", d.Current().Version, err)
+			}
+		}
+	}
+}
+
+// ReloadIfChanged reloads the directory when the file mtime differs from the
+// last file loaded. It reports whether a new version was swapped in. If the
+// new file cannot be read or parsed the previous version stays in use and the
+// file is retried on the next poll (it may have been half-written); a file
+// rejected by the validation policy is not read again until its mtime changes.
+func (d *Directory) ReloadIfChanged() (bool, error) {
+	fi, err := os.Stat(d.path)
+	if err != nil {
+		return false, d.setErr(fmt.Errorf("stat directory csv: %w", err))
+	}
+
+	d.mu.Lock()
+	seen := fi.ModTime().Equal(d.seenMod)
+	d.mu.Unlock()
+	if seen {
+		return false, nil
+	}
+
+	cur := d.Current()
+	snap, err := d.load()
+	var rejected *policyRejection
+	if err == nil || errors.As(err, &rejected) {
+		d.mu.Lock()
+		d.seenMod = fi.ModTime()
+		d.mu.Unlock()
+	}
+	if err != nil {
+		return false, d.setErr(err)
+	}
+	d.setErr(nil)
+
+	if snap.Version == cur.Version {
+		// touched but unchanged: keep the current snapshot, including its
+		// ModTime, so Last-Modified (and clients' 304s) stay valid
+		return false, nil
+	}
+
+	d.current.Store(snap)
+	log.Printf("directory reloaded: version %s (provinsi=%d, kabkot=%d)", snap.Version, len(snap.Provinsi), len(snap.Kabkot))
+
+	d.mu.Lock()
+	hooks := append([]func(*DirectorySnapshot){}, d.onReload...)
+	d.mu.Unlock()
+	for _, fn := range hooks {
+		fn(snap)
+	}
+	return true, nil
+}
+
+func (d *Directory) setErr(err error) error {
+	d.mu.Lock()
+	d.lastErr = err
+	d.mu.Unlock()
+	return err
+}
+
+// load reads, parses and validates the CSV file into a new snapshot
+func (d *Directory) load() (*DirectorySnapshot, error) {
+	fi, err := os.Stat(d.path)
+	if err != nil {
+		return nil, fmt.Errorf("stat directory csv: %w", err)
+	}
+	b, err := os.ReadFile(d.path)
+	if err != nil {
+		return nil, fmt.Errorf("open directory csv: %w", err)
+	}
+
+	res, err := parseDirectoryCSV(bytes.NewReader(b), d.rules, d.regions)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(b)
+	version := hex.EncodeToString(sum[:8])
+
+	// Row-level issues are kept even when the policy rejects the file, so
+	// /api/v1/directory/validation can show why
+	policyErr := d.policy.Check(res.Issues)
+	errs, warns := countIssues(res.Issues)
+	d.mu.Lock()
+	d.validation = &DirectoryValidation{
+		Version:   version,
+		CheckedAt: time.Now(),
+		Accepted:  policyErr == nil,
+		Errors:    errs,
+		Warnings:  warns,
+		Issues:    res.Issues,
+	}
+	d.mu.Unlock()
+	if policyErr != nil {
+		return nil, &policyRejection{policyErr}
+	}
+	if errs+warns > 0 {
+		log.Printf("directory version %s: %d validation errors, %d warnings (see /api/v1/directory/validation)", version, errs, warns)
+	}
+	if err := validateDirectory(res); err != nil {
+		return nil, err
+	}
+
+	return &DirectorySnapshot{
+		DirectoryResult: res,
+		Version:         version,
+		ModTime:         fi.ModTime(),
+		LoadedAt:        time.Now(),
+	}, nil
+}
+
+// policyRejection is a load error caused by the validation policy rather
+// than by reading or parsing the file
+type policyRejection struct {
+	err error
+}
+
+func (e *policyRejection) Error() string { return e.err.Error() }
+func (e *policyRejection) Unwrap() error { return e.err }
+
+// validateDirectory rejects directories the KPI endpoints cannot work with
+func validateDirectory(res DirectoryResult) error {
+	if len(res.Provinsi) == 0 {
+		return fmt.Errorf("directory csv has no provinsi staff")
+	}
+	if len(res.Kabkot) == 0 {
+		return fmt.Errorf("directory csv has no ketua kabkot")
+	}
+	return nil
+}
+
+// reportCachePrefixes are the cache key prefixes of the report endpoints.
+// Every report embeds directory data (names, scope, instansi, ...) as well as
+// Plane data, so they are invalidated on directory reloads and data changes.
+var reportCachePrefixes = []string{
+	"kpi_provinsi",
+	"kpi_kabkot",
+	"heatmap",
+	"issues_detail",
+	"timeline",
+	"leaderboard",
+	"workload",
+	"burnup",
+	"cfd",
+	"cycle_time",
+	"forecast",
+	"data_quality",
+	"directory_reconciliation",
+	"onboarding",
+	"report",
+}
+
+// InvalidateDirectoryCache drops cached results built from an older directory.
+// It is registered as a Directory reload hook in main.
+func (s *Server) InvalidateDirectoryCache(snap *DirectorySnapshot) {
+	n := 0
+	for _, prefix := range reportCachePrefixes {
+		n += s.Cache.DeletePrefix(endpointCachePrefix(prefix))
+	}
+	log.Printf("directory version %s: invalidated %d cache entries", snap.Version, n)
+}
@@ -0,0 +1,181 @@
+package httpx
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Severity of a directory validation issue
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Directory validation issue codes
+const (
+	issueColumnShift     = "column_shift"
+	issueMalformedEmail  = "malformed_email"
+	issueDuplicateEmail  = "duplicate_email"
+	issueUnknownJabatan  = "unknown_jabatan"
+	issueUnknownInstansi = "unknown_instansi"
+	issueEmbeddedNewline = "embedded_newline"
+	issueMissingField    = "missing_field"
+)
+
+// DirectoryIssue is one validation finding on a line of the directory CSV
+type DirectoryIssue struct {
+	Line     int    `json:"line"`
+	Severity string `json:"severity"`
+	Code     string `json:"code"`
+	Column   string `json:"column,omitempty"`
+	Value    string `json:"value,omitempty"`
+	Message  string `json:"message"`
+}
+
+// ValidationPolicy decides whether validation issues fail a directory load
+// (at startup and on reload; a rejected reload keeps the previous version)
+type ValidationPolicy string
+
+const (
+	PolicyContinue      ValidationPolicy = "continue"        // load anyway, issues are only reported
+	PolicyFailOnError   ValidationPolicy = "fail-on-error"   // reject the file when it has errors
+	PolicyFailOnWarning ValidationPolicy = "fail-on-warning" // reject the file on any issue
+)
+
+// ParseValidationPolicy reads DIRECTORY_VALIDATION_POLICY; "" means continue
+func ParseValidationPolicy(v string) (ValidationPolicy, error) {
+	switch p := ValidationPolicy(strings.ToLower(strings.TrimSpace(v))); p {
+	case "":
+		return PolicyContinue, nil
+	case PolicyContinue, PolicyFailOnError, PolicyFailOnWarning:
+		return p, nil
+	}
+	return "", fmt.Errorf("invalid directory validation policy %q (supported: continue, fail-on-error, fail-on-warning)", v)
+}
+
+// Check returns an error when issues are not acceptable under the policy
+func (p ValidationPolicy) Check(issues []DirectoryIssue) error {
+	errs, warns := countIssues(issues)
+	switch {
+	case p == PolicyFailOnError && errs > 0,
+		p == PolicyFailOnWarning && errs+warns > 0:
+		first := issues[0]
+		if p == PolicyFailOnError {
+			for _, is := range issues {
+				if is.Severity == SeverityError {
+					first = is
+					break
+				}
+			}
+		}
+		return fmt.Errorf("directory csv rejected by validation policy %s: %d errors, %d warnings (first: line %d %s: %s)",
+			p, errs, warns, first.Line, first.Code, first.Message)
+	}
+	return nil
+}
+
+// countIssues returns the number of errors and warnings
+func countIssues(issues []DirectoryIssue) (errs, warns int) {
+	for _, is := range issues {
+		if is.Severity == SeverityError {
+			errs++
+		} else {
+			warns++
+		}
+	}
+	return errs, warns
+}
+
+// emailPattern is deliberately loose: one @, no spaces, a dot in the domain
+var emailPattern = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+
+// directoryValidator collects issues while parseDirectoryCSV reads rows
+type directoryValidator struct {
+	header  []string
+	rules   *RoleRules
+	regions *RegionRegistry
+	seen    map[string]int // email -> first line
+	issues  []DirectoryIssue
+}
+
+func newDirectoryValidator(header []string, rules *RoleRules, regions *RegionRegistry) *directoryValidator {
+	return &directoryValidator{header: header, rules: rules, regions: regions, seen: map[string]int{}}
+}
+
+func (v *directoryValidator) add(line int, severity, code, column, value, msg string, args ...any) {
+	v.issues = append(v.issues, DirectoryIssue{
+		Line: line, Severity: severity, Code: code, Column: column, Value: value,
+		Message: fmt.Sprintf(msg, args...),
+	})
+}
+
+// checkRow validates one record; nama, email, inst and jab are the trimmed
+// (email: normalised) values the parser uses. It returns false for shifted
+// rows, whose values are in the wrong columns and must not be used.
+func (v *directoryValidator) checkRow(line int, rec []string, nama, email, inst, jab string) bool {
+	// Column shift: wrong width, or an email where the name should be
+	shifted := false
+	if len(rec) != len(v.header) {
+		shifted = true
+		v.add(line, SeverityError, issueColumnShift, "", "",
+			"baris punya %d kolom, header %d; baris dilewati", len(rec), len(v.header))
+	} else if strings.Contains(nama, "@") {
+		shifted = true
+		v.add(line, SeverityError, issueColumnShift, "Nama", nama,
+			"kolom Nama berisi email; kolom kemungkinan bergeser, baris dilewati")
+	}
+
+	for i, val := range rec {
+		if strings.ContainsAny(val, "\r\n") {
+			column := ""
+			if i < len(v.header) {
+				column = v.header[i]
+			}
+			v.add(line, SeverityWarning, issueEmbeddedNewline, column, val,
+				"nilai berisi baris baru")
+		}
+	}
+	if shifted {
+		return false
+	}
+
+	if nama == "" || email == "" || inst == "" || jab == "" {
+		v.add(line, SeverityWarning, issueMissingField, "", "",
+			"kolom wajib kosong; baris tidak masuk report")
+	}
+
+	if email != "" {
+		if !emailPattern.MatchString(email) {
+			v.add(line, SeverityError, issueMalformedEmail, "Akun Gmail", email,
+				"format email tidak valid")
+		}
+		if first, dup := v.seen[email]; dup {
+			v.add(line, SeverityWarning, issueDuplicateEmail, "Akun Gmail", email,
+				"email sudah dipakai di baris %d; hanya baris pertama yang dipakai report", first)
+		} else {
+			v.seen[email] = line
+		}
+	}
+
+	if inst != "" {
+		switch scope := deriveScope(inst); {
+		case scope == "":
+			v.add(line, SeverityWarning, issueUnknownInstansi, "Asal Instansi", inst,
+				"instansi bukan BPS provinsi/kabupaten/kota; baris tidak masuk report")
+		case scope == "kabkot":
+			if _, ok := v.regions.ByInstansi(inst); !ok {
+				v.add(line, SeverityWarning, issueUnknownInstansi, "Asal Instansi", inst,
+					"instansi kab/kota tidak ada di wilayah.csv; kab/kota hanya bisa dibaca dari kode di nama Plane")
+			}
+		}
+	}
+
+	if jab != "" {
+		if _, ok := v.rules.Classify(jab, deriveScope(inst)); !ok {
+			v.add(line, SeverityWarning, issueUnknownJabatan, "Jabatan Dalam Tim SE2026", jab,
+				"jabatan tidak cocok dengan aturan mana pun di file aturan jabatan; baris tidak masuk report")
+		}
+	}
+	return true
+}
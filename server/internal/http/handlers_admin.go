@@ -0,0 +1,85 @@
+package httpx
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"serumpun-data-api/internal/cache"
+)
+
+// requireAdmin guards the admin endpoints with AdminToken (as a Bearer token).
+// Without a configured token the endpoints are disabled (404): purging the
+// cache or forcing a flush must never be open by default.
+func (s *Server) requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.AdminToken == "" {
+			http.NotFound(w, r)
+			return
+		}
+		got := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(got), []byte(s.AdminToken)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// AdminCache lists cache statistics and entries (key, size, age), optionally
+// limited to keys starting with ?prefix=
+func (s *Server) AdminCache(w http.ResponseWriter, r *http.Request) {
+	writeAdminJSON(w, struct {
+		Stats   cache.Stats       `json:"stats"`
+		Entries []cache.EntryInfo `json:"entries"`
+	}{
+		Stats:   s.Cache.Stats(),
+		Entries: s.Cache.Entries(r.URL.Query().Get("prefix")),
+	})
+}
+
+// AdminCachePurge removes the entries starting with ?prefix=; ?all=true
+// empties the whole cache
+func (s *Server) AdminCachePurge(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	prefix := q.Get("prefix")
+	all, _ := strconv.ParseBool(q.Get("all"))
+	if prefix == "" && !all {
+		http.Error(w, "prefix is required (or all=true)", http.StatusBadRequest)
+		return
+	}
+	if all {
+		prefix = ""
+	}
+
+	writeAdminJSON(w, map[string]any{
+		"prefix": prefix,
+		"purged": s.Cache.DeletePrefix(prefix),
+	})
+}
+
+// AdminNotify shows the LISTEN/NOTIFY invalidation status
+func (s *Server) AdminNotify(w http.ResponseWriter, r *http.Request) {
+	if s.Notifier == nil {
+		writeAdminJSON(w, map[string]any{"enabled": false, "mode": "ttl-only"})
+		return
+	}
+	writeAdminJSON(w, struct {
+		Enabled bool `json:"enabled"`
+		NotifyStatus
+	}{true, s.Notifier.Status()})
+}
+
+func writeAdminJSON(w http.ResponseWriter, v any) {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		http.Error(w, "failed to encode response: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(b)
+}
@@ -1,304 +1,214 @@
 package httpx
 
 import (
-	"context"
 	"net/http"
-	"os"
-	"time"
+
+	"serumpun-data-api/internal/plane"
 )
 
 // IssuesDetailTemplate handles Issues Detail using SQL template
 func (s *Server) IssuesDetailTemplate(w http.ResponseWriter, r *http.Request) {
 	// Parse query parameters for filtering
-	filters := map[string]string{
-		"scope":    r.URL.Query().Get("scope"),
-		"kab_kota": r.URL.Query().Get("kab_kota"),
-		"bidang":   r.URL.Query().Get("bidang"),
-		"status":   r.URL.Query().Get("status"),
-	}
+	filters := parseFilters(r, issuesDetailFilters)
 
-	// Build cache key
-	cacheKey := buildCacheKey("issues_detail", filters)
-
-	// Check cache
-	if b, ok := s.Cache.Get(cacheKey); ok {
-		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
-		w.Header().Set("Cache-Control", "public, max-age=30")
-		w.WriteHeader(http.StatusOK)
-		_, _ = w.Write(b)
+	// Resolve Plane project (?project=, default from config)
+	project, ok := s.requestProject(w, r)
+	if !ok {
 		return
 	}
 
-	// Load directory from CSV
-	csvPath := os.Getenv("DIRECTORY_CSV_PATH")
-	if csvPath == "" {
-		csvPath = "data/daftar_pengguna_serumpun.csv"
-	}
+	s.serveReport(w, r, reportSpec{
+		Name:      "issues_detail",
+		Project:   project,
+		Filters:   filters,
+		Templates: []string{"issues_detail.sql"},
+		Build: func() (sqlQuery, error) {
+			return s.issuesDetailQuery(project, filters)
+		},
+	})
+}
 
-	dir, err := LoadDirectoryFromCSV(csvPath)
-	if err != nil {
-		http.Error(w, "failed to load directory: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
+// TimelineTemplate handles Timeline using SQL template
+func (s *Server) TimelineTemplate(w http.ResponseWriter, r *http.Request) {
+	// Parse query parameters for filtering
+	filters := parseFilters(r, timelineFilters)
 
-	// Load SQL template
-	sqlTemplate, err := s.Queries.Load("issues_detail.sql")
-	if err != nil {
-		http.Error(w, "failed to load sql template: "+err.Error(), http.StatusInternalServerError)
+	// Resolve Plane project (?project=, default from config)
+	project, ok := s.requestProject(w, r)
+	if !ok {
 		return
 	}
 
-	// Build dynamic parts
-	allRows := append(dir.Provinsi, dir.Kabkot...)
-	namaCases := buildNamaCases(allRows)
-	scopeCases := buildScopeCases(allRows)
-	whereClause := buildWhereClause(filters)
-
-	// Replace placeholders
-	sql := buildDynamicSQL(sqlTemplate, map[string]string{
-		"{{NAMA_CASES}}":   namaCases,
-		"{{SCOPE_CASES}}":  scopeCases,
-		"{{WHERE_CLAUSE}}": whereClause,
+	s.serveReport(w, r, reportSpec{
+		Name:      "timeline",
+		Project:   project,
+		Filters:   filters,
+		Templates: []string{"timeline.sql"},
+		Build: func() (sqlQuery, error) {
+			return s.timelineQuery(project, filters)
+		},
 	})
+}
 
-	// Execute query
-	ctx, cancel := context.WithTimeout(r.Context(), 25*time.Second)
-	defer cancel()
+// LeaderboardTemplate handles Leaderboard using SQL template
+func (s *Server) LeaderboardTemplate(w http.ResponseWriter, r *http.Request) {
+	// Parse query parameters for filtering
+	filters := parseFilters(r, leaderboardFilters)
 
-	b, err := QueryToCSV(ctx, s.DB, sql)
-	if err != nil {
-		http.Error(w, "query failed: "+err.Error(), http.StatusInternalServerError)
+	// Resolve Plane project (?project=, default from config)
+	project, ok := s.requestProject(w, r)
+	if !ok {
 		return
 	}
 
-	// Set cache
-	s.Cache.Set(cacheKey, b)
-
-	// Write response
-	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
-	w.Header().Set("Cache-Control", "public, max-age=30")
-	w.WriteHeader(http.StatusOK)
-	_, _ = w.Write(b)
+	s.serveReport(w, r, reportSpec{
+		Name:      "leaderboard",
+		Project:   project,
+		Filters:   filters,
+		Templates: []string{"leaderboard.sql"},
+		Build: func() (sqlQuery, error) {
+			return s.leaderboardQuery(project, filters)
+		},
+	})
 }
 
-// TimelineTemplate handles Timeline using SQL template
-func (s *Server) TimelineTemplate(w http.ResponseWriter, r *http.Request) {
+// WorkloadTemplate handles Workload using SQL template
+func (s *Server) WorkloadTemplate(w http.ResponseWriter, r *http.Request) {
 	// Parse query parameters for filtering
-	filters := map[string]string{
-		"scope":    r.URL.Query().Get("scope"),
-		"kab_kota": r.URL.Query().Get("kab_kota"),
-		"bidang":   r.URL.Query().Get("bidang"),
-		"status":   r.URL.Query().Get("status"),
+	filters := parseFilters(r, workloadFilters)
+	options, err := parseCompare(r)
+	if err != nil {
+		writeError(w, err)
+		return
 	}
 
-	// Build cache key
-	cacheKey := buildCacheKey("timeline", filters)
-
-	// Check cache
-	if b, ok := s.Cache.Get(cacheKey); ok {
-		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
-		w.Header().Set("Cache-Control", "public, max-age=30")
-		w.WriteHeader(http.StatusOK)
-		_, _ = w.Write(b)
+	// Resolve Plane project (?project=, default from config)
+	project, ok := s.requestProject(w, r)
+	if !ok {
 		return
 	}
 
-	// Load directory from CSV
-	csvPath := os.Getenv("DIRECTORY_CSV_PATH")
-	if csvPath == "" {
-		csvPath = "data/daftar_pengguna_serumpun.csv"
-	}
+	// ?compare=7d adds previous-value and delta columns from the snapshots
+	s.serveReport(w, r, s.withComparison(reportSpec{
+		Name:      "workload",
+		Project:   project,
+		Filters:   filters,
+		Options:   options,
+		Templates: []string{"workload.sql"},
+		Build: func() (sqlQuery, error) {
+			return s.workloadQuery(project, filters)
+		},
+	}))
+}
 
-	dir, err := LoadDirectoryFromCSV(csvPath)
-	if err != nil {
-		http.Error(w, "failed to load directory: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
+// issuesDetailQuery renders the Issues Detail template for a project and filters
+func (s *Server) issuesDetailQuery(project plane.Project, filters map[string]string) (sqlQuery, error) {
+	// Current directory version (loaded at startup, hot-reloaded)
+	dir := s.Directory.Current()
 
 	// Load SQL template
-	sqlTemplate, err := s.Queries.Load("timeline.sql")
+	sqlTemplate, err := s.Queries.Load("issues_detail.sql")
 	if err != nil {
-		http.Error(w, "failed to load sql template: "+err.Error(), http.StatusInternalServerError)
-		return
+		return sqlQuery{}, errorStatus(http.StatusInternalServerError, "failed to load sql template: %v", err)
 	}
 
 	// Build dynamic parts
-	allRows := append(dir.Provinsi, dir.Kabkot...)
-	namaCases := buildNamaCases(allRows)
-	scopeCases := buildScopeCases(allRows)
-	whereClause := buildWhereClause(filters)
+	qb := newQueryBuilder(project)
+	directory := buildDirectoryArrays(qb, dir.All(), s.Regions)
+	wilayah := buildRegionArrays(qb, s.Regions)
+	kodePattern := qb.textArg(s.Regions.KodePattern())
+	whereClause, err := buildWhereClause(qb, issuesDetailFilters, filters)
+	if err != nil {
+		return sqlQuery{}, errorStatus(http.StatusBadRequest, "%v", err)
+	}
 
 	// Replace placeholders
-	sql := buildDynamicSQL(sqlTemplate, map[string]string{
-		"{{NAMA_CASES}}":   namaCases,
-		"{{SCOPE_CASES}}":  scopeCases,
+	return qb.build(sqlTemplate, map[string]string{
+		"{{DIRECTORY}}":    directory,
+		"{{WILAYAH}}":      wilayah,
+		"{{KODE_PATTERN}}": kodePattern,
 		"{{WHERE_CLAUSE}}": whereClause,
-	})
+	}), nil
+}
 
-	// Execute query
-	ctx, cancel := context.WithTimeout(r.Context(), 25*time.Second)
-	defer cancel()
+// timelineQuery renders the Timeline template for a project and filters
+func (s *Server) timelineQuery(project plane.Project, filters map[string]string) (sqlQuery, error) {
+	// Current directory version (loaded at startup, hot-reloaded)
+	dir := s.Directory.Current()
 
-	b, err := QueryToCSV(ctx, s.DB, sql)
+	// Load SQL template
+	sqlTemplate, err := s.Queries.Load("timeline.sql")
 	if err != nil {
-		http.Error(w, "query failed: "+err.Error(), http.StatusInternalServerError)
-		return
+		return sqlQuery{}, errorStatus(http.StatusInternalServerError, "failed to load sql template: %v", err)
 	}
 
-	// Set cache
-	s.Cache.Set(cacheKey, b)
-
-	// Write response
-	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
-	w.Header().Set("Cache-Control", "public, max-age=30")
-	w.WriteHeader(http.StatusOK)
-	_, _ = w.Write(b)
-}
-
-// LeaderboardTemplate handles Leaderboard using SQL template
-func (s *Server) LeaderboardTemplate(w http.ResponseWriter, r *http.Request) {
-	// Parse query parameters for filtering
-	filters := map[string]string{
-		"scope":  r.URL.Query().Get("scope"),
-		"bidang": r.URL.Query().Get("bidang"),
+	// Build dynamic parts
+	qb := newQueryBuilder(project)
+	directory := buildDirectoryArrays(qb, dir.All(), s.Regions)
+	wilayah := buildRegionArrays(qb, s.Regions)
+	kodePattern := qb.textArg(s.Regions.KodePattern())
+	whereClause, err := buildWhereClause(qb, timelineFilters, filters)
+	if err != nil {
+		return sqlQuery{}, errorStatus(http.StatusBadRequest, "%v", err)
 	}
 
-	// Build cache key
-	cacheKey := buildCacheKey("leaderboard", filters)
-
-	// Check cache
-	if b, ok := s.Cache.Get(cacheKey); ok {
-		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
-		w.Header().Set("Cache-Control", "public, max-age=30")
-		w.WriteHeader(http.StatusOK)
-		_, _ = w.Write(b)
-		return
-	}
+	// Replace placeholders
+	return qb.build(sqlTemplate, map[string]string{
+		"{{DIRECTORY}}":    directory,
+		"{{WILAYAH}}":      wilayah,
+		"{{KODE_PATTERN}}": kodePattern,
+		"{{WHERE_CLAUSE}}": whereClause,
+	}), nil
+}
 
-	// Load directory from CSV
-	csvPath := os.Getenv("DIRECTORY_CSV_PATH")
-	if csvPath == "" {
-		csvPath = "data/daftar_pengguna_serumpun.csv"
-	}
-
-	dir, err := LoadDirectoryFromCSV(csvPath)
-	if err != nil {
-		http.Error(w, "failed to load directory: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
+// leaderboardQuery renders the Leaderboard template for a project and filters
+func (s *Server) leaderboardQuery(project plane.Project, filters map[string]string) (sqlQuery, error) {
+	// Current directory version (loaded at startup, hot-reloaded)
+	dir := s.Directory.Current()
 
 	// Load SQL template
 	sqlTemplate, err := s.Queries.Load("leaderboard.sql")
 	if err != nil {
-		http.Error(w, "failed to load sql template: "+err.Error(), http.StatusInternalServerError)
-		return
+		return sqlQuery{}, errorStatus(http.StatusInternalServerError, "failed to load sql template: %v", err)
 	}
 
 	// Build dynamic parts
-	allRows := append(dir.Provinsi, dir.Kabkot...)
-	namaCases := buildNamaCases(allRows)
-	scopeCases := buildScopeCases(allRows)
-	instansiCases := buildInstansiCases(allRows)
-	whereClause := buildWhereClause(filters)
-
-	// Replace placeholders
-	sql := buildDynamicSQL(sqlTemplate, map[string]string{
-		"{{NAMA_CASES}}":     namaCases,
-		"{{SCOPE_CASES}}":    scopeCases,
-		"{{INSTANSI_CASES}}": instansiCases,
-		"{{WHERE_CLAUSE}}":   whereClause,
-	})
-
-	// Execute query
-	ctx, cancel := context.WithTimeout(r.Context(), 25*time.Second)
-	defer cancel()
-
-	b, err := QueryToCSV(ctx, s.DB, sql)
+	qb := newQueryBuilder(project)
+	directory := buildDirectoryArrays(qb, dir.All(), s.Regions)
+	whereClause, err := buildWhereClause(qb, leaderboardFilters, filters)
 	if err != nil {
-		http.Error(w, "query failed: "+err.Error(), http.StatusInternalServerError)
-		return
+		return sqlQuery{}, errorStatus(http.StatusBadRequest, "%v", err)
 	}
 
-	// Set cache
-	s.Cache.Set(cacheKey, b)
-
-	// Write response
-	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
-	w.Header().Set("Cache-Control", "public, max-age=30")
-	w.WriteHeader(http.StatusOK)
-	_, _ = w.Write(b)
+	// Replace placeholders
+	return qb.build(sqlTemplate, map[string]string{
+		"{{DIRECTORY}}":    directory,
+		"{{WHERE_CLAUSE}}": whereClause,
+	}), nil
 }
 
-// WorkloadTemplate handles Workload using SQL template
-func (s *Server) WorkloadTemplate(w http.ResponseWriter, r *http.Request) {
-	// Parse query parameters for filtering
-	filters := map[string]string{
-		"scope":  r.URL.Query().Get("scope"),
-		"bidang": r.URL.Query().Get("bidang"),
-	}
-
-	// Build cache key
-	cacheKey := buildCacheKey("workload", filters)
-
-	// Check cache
-	if b, ok := s.Cache.Get(cacheKey); ok {
-		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
-		w.Header().Set("Cache-Control", "public, max-age=30")
-		w.WriteHeader(http.StatusOK)
-		_, _ = w.Write(b)
-		return
-	}
-
-	// Load directory from CSV
-	csvPath := os.Getenv("DIRECTORY_CSV_PATH")
-	if csvPath == "" {
-		csvPath = "data/daftar_pengguna_serumpun.csv"
-	}
-
-	dir, err := LoadDirectoryFromCSV(csvPath)
-	if err != nil {
-		http.Error(w, "failed to load directory: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
+// workloadQuery renders the Workload template for a project and filters
+func (s *Server) workloadQuery(project plane.Project, filters map[string]string) (sqlQuery, error) {
+	// Current directory version (loaded at startup, hot-reloaded)
+	dir := s.Directory.Current()
 
 	// Load SQL template
 	sqlTemplate, err := s.Queries.Load("workload.sql")
 	if err != nil {
-		http.Error(w, "failed to load sql template: "+err.Error(), http.StatusInternalServerError)
-		return
+		return sqlQuery{}, errorStatus(http.StatusInternalServerError, "failed to load sql template: %v", err)
 	}
 
 	// Build dynamic parts
-	allRows := append(dir.Provinsi, dir.Kabkot...)
-	namaCases := buildNamaCases(allRows)
-	scopeCases := buildScopeCases(allRows)
-	instansiCases := buildInstansiCases(allRows)
-	whereClause := buildWhereClause(filters)
-
-	// Replace placeholders
-	sql := buildDynamicSQL(sqlTemplate, map[string]string{
-		"{{NAMA_CASES}}":     namaCases,
-		"{{SCOPE_CASES}}":    scopeCases,
-		"{{INSTANSI_CASES}}": instansiCases,
-		"{{WHERE_CLAUSE}}":   whereClause,
-	})
-
-	// Execute query
-	ctx, cancel := context.WithTimeout(r.Context(), 25*time.Second)
-	defer cancel()
-
-	b, err := QueryToCSV(ctx, s.DB, sql)
+	qb := newQueryBuilder(project)
+	directory := buildDirectoryArrays(qb, dir.All(), s.Regions)
+	whereClause, err := buildWhereClause(qb, workloadFilters, filters)
 	if err != nil {
-		http.Error(w, "query failed: "+err.Error(), http.StatusInternalServerError)
-		return
+		return sqlQuery{}, errorStatus(http.StatusBadRequest, "%v", err)
 	}
 
-	// Set cache
-	s.Cache.Set(cacheKey, b)
-
-	// Write response
-	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
-	w.Header().Set("Cache-Control", "public, max-age=30")
-	w.WriteHeader(http.StatusOK)
-	_, _ = w.Write(b)
+	// Replace placeholders
+	return qb.build(sqlTemplate, map[string]string{
+		"{{DIRECTORY}}":    directory,
+		"{{WHERE_CLAUSE}}": whereClause,
+	}), nil
 }
@@ -3,31 +3,35 @@ package httpx
 import (
 	"fmt"
 	"net/http"
-	"os"
 	"strings"
+	"time"
 )
 
 // DebugDirectory shows directory loading status
 func (s *Server) DebugDirectory(w http.ResponseWriter, r *http.Request) {
-	csvPath := os.Getenv("DIRECTORY_CSV_PATH")
-	if csvPath == "" {
-		csvPath = "data/daftar_pengguna_serumpun.csv"
-	}
+	dir := s.Directory.Current()
 
-	dir, err := LoadDirectoryFromCSV(csvPath)
-	if err != nil {
-		http.Error(w, "failed to load directory: "+err.Error(), http.StatusInternalServerError)
-		return
+	v := s.Directory.Validation()
+
+	lastErr := "-"
+	if err := s.Directory.LastError(); err != nil {
+		lastErr = err.Error()
 	}
 
 	response := fmt.Sprintf(`Directory Loading Status:
 CSV Path: %s
+Version: %s
+File Modified: %s
+Loaded At: %s
+Last Reload Error: %s
+Validation (%s): %d errors, %d warnings
 Provinsi Count: %d
 Kabkot Count: %d
 Bidang List: %v
 
 Sample Provinsi (first 3):
-`, csvPath, len(dir.Provinsi), len(dir.Kabkot), dir.BidangList)
+`, s.Directory.Path(), dir.Version, dir.ModTime.Format(time.RFC3339), dir.LoadedAt.Format(time.RFC3339),
+		lastErr, s.Directory.Policy(), v.Errors, v.Warnings, len(dir.Provinsi), len(dir.Kabkot), dir.BidangList)
 
 	for i, row := range dir.Provinsi {
 		if i >= 3 {
@@ -44,22 +48,12 @@ Sample Provinsi (first 3):
 		response += fmt.Sprintf("  - %s (%s) - %s - %s - %s\n", row.Nama, row.Email, row.Instansi, row.Jabatan, row.Bidang)
 	}
 
-	// Test building cases
-	response += "\n\nTest buildNamaCases (first 100 chars):\n"
-	namaCases := buildNamaCases(dir.Provinsi)
-	if len(namaCases) > 100 {
-		response += namaCases[:100] + "..."
-	} else {
-		response += namaCases
-	}
-
-	response += "\n\nTest buildEmailList (first 100 chars):\n"
-	emails := buildEmailList(dir.Provinsi)
-	if len(emails) > 100 {
-		response += emails[:100] + "..."
-	} else {
-		response += emails
-	}
+	// Test building directory arrays
+	var qb queryBuilder
+	response += "\n\nTest buildDirectoryArrays (provinsi):\n"
+	placeholders := buildDirectoryArrays(&qb, dir.Provinsi, s.Regions)
+	emails, _ := qb.args[0].([]string)
+	response += fmt.Sprintf("unnest(%s) -> %d rows", placeholders, len(emails))
 
 	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 	w.WriteHeader(http.StatusOK)
@@ -68,17 +62,13 @@ Sample Provinsi (first 3):
 
 // DebugSQL shows generated SQL for KPI Provinsi
 func (s *Server) DebugSQL(w http.ResponseWriter, r *http.Request) {
-	csvPath := os.Getenv("DIRECTORY_CSV_PATH")
-	if csvPath == "" {
-		csvPath = "data/daftar_pengguna_serumpun.csv"
-	}
-
-	dir, err := LoadDirectoryFromCSV(csvPath)
-	if err != nil {
-		http.Error(w, "failed to load directory: "+err.Error(), http.StatusInternalServerError)
+	project, ok := s.requestProject(w, r)
+	if !ok {
 		return
 	}
 
+	dir := s.Directory.Current()
+
 	// Load SQL template
 	sqlTemplate, err := s.Queries.Load("kpi_provinsi.sql")
 	if err != nil {
@@ -87,38 +77,72 @@ func (s *Server) DebugSQL(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Build dynamic parts
-	namaCases := buildNamaCases(dir.Provinsi)
-	bidangCases := buildBidangCases(dir.Provinsi)
-	jabatanCases := buildJabatanCases(dir.Provinsi)
-	emails := buildEmailList(dir.Provinsi)
-	whereClause := buildAdditionalWhere(map[string]string{})
+	qb := newQueryBuilder(project)
+	directory := buildDirectoryArrays(qb, dir.Provinsi, s.Regions)
+	whereClause, err := buildAdditionalWhere(qb, kpiProvinsiFilters, parseFilters(r, kpiProvinsiFilters))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	// Replace placeholders
-	sql := buildDynamicSQL(sqlTemplate, map[string]string{
-		"{{NAMA_CASES}}":    namaCases,
-		"{{BIDANG_CASES}}":  bidangCases,
-		"{{JABATAN_CASES}}": jabatanCases,
-		"{{EMAILS}}":        emails,
-		"{{WHERE_CLAUSE}}":  whereClause,
+	q := qb.build(sqlTemplate, map[string]string{
+		"{{DIRECTORY}}":    directory,
+		"{{WHERE_CLAUSE}}": whereClause,
 	})
 
 	response := fmt.Sprintf(`Generated SQL for KPI Provinsi:
+Project: %s (workspace %s, project %s)
 Provinsi Count: %d
-namaCases lines: %d
-bidangCases lines: %d
-jabatanCases lines: %d
-emails count: %d
+directory arrays: %s
+args count: %d
+sql length: %d bytes
 
 Full SQL:
 %s
-`, len(dir.Provinsi),
-		len(strings.Split(namaCases, "\n")),
-		len(strings.Split(bidangCases, "\n")),
-		len(strings.Split(jabatanCases, "\n")),
-		len(strings.Split(emails, ",")),
-		sql)
+`, project.Key, project.WorkspaceID, project.ProjectID,
+		len(dir.Provinsi),
+		directory,
+		len(q.Args),
+		len(q.SQL),
+		q.SQL)
 
 	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte(response))
 }
+
+// DirectoryValidation lists the per-line issues of the most recently read
+// directory CSV (?severity=error|warning, ?code=)
+func (s *Server) DirectoryValidation(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	severity := strings.ToLower(strings.TrimSpace(q.Get("severity")))
+	if severity != "" && severity != SeverityError && severity != SeverityWarning {
+		http.Error(w, fmt.Sprintf("unsupported severity %q (supported: error, warning)", severity), http.StatusBadRequest)
+		return
+	}
+	code := strings.TrimSpace(q.Get("code"))
+
+	v := s.Directory.Validation()
+	issues := []DirectoryIssue{}
+	for _, is := range v.Issues {
+		if (severity == "" || is.Severity == severity) && (code == "" || is.Code == code) {
+			issues = append(issues, is)
+		}
+	}
+
+	writeAdminJSON(w, struct {
+		Path           string           `json:"path"`
+		Policy         ValidationPolicy `json:"policy"`
+		CurrentVersion string           `json:"current_version"`
+		DirectoryValidation
+	}{
+		Path:           s.Directory.Path(),
+		Policy:         s.Directory.Policy(),
+		CurrentVersion: s.Directory.Current().Version,
+		DirectoryValidation: DirectoryValidation{
+			Version: v.Version, CheckedAt: v.CheckedAt, Accepted: v.Accepted,
+			Errors: v.Errors, Warnings: v.Warnings, Issues: issues,
+		},
+	})
+}
@@ -0,0 +1,102 @@
+package httpx
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"serumpun-data-api/internal/plane"
+)
+
+// workbookSheet is one sheet of the /report.xlsx bundle
+type workbookSheet struct {
+	Name     string
+	Template string
+	Filters  filterColumns
+	Query    func(s *Server, project plane.Project, filters map[string]string) (sqlQuery, error)
+}
+
+// workbookSheets are the reports bundled in /report.xlsx, in sheet order.
+// Each sheet is rendered from the same template as its own endpoint.
+var workbookSheets = []workbookSheet{
+	{"KPI Provinsi", "kpi_provinsi.sql", kpiProvinsiFilters, (*Server).kpiProvinsiQuery},
+	{"KPI Kabkot", "kpi_kabkot.sql", kpiKabkotFilters, (*Server).kpiKabkotQuery},
+	{"Heatmap", "heatmap.sql", heatmapFilters, (*Server).heatmapQuery},
+	{"Timeline", "timeline.sql", timelineFilters, (*Server).timelineQuery},
+	{"Leaderboard", "leaderboard.sql", leaderboardFilters, (*Server).leaderboardQuery},
+	{"Workload", "workload.sql", workloadFilters, (*Server).workloadQuery},
+}
+
+// ReportWorkbook serves every leadership report as one .xlsx workbook. Query
+// parameters are applied to each sheet whose endpoint accepts them.
+func (s *Server) ReportWorkbook(w http.ResponseWriter, r *http.Request) {
+	// Resolve Plane project (?project=, default from config)
+	project, ok := s.requestProject(w, r)
+	if !ok {
+		return
+	}
+
+	// Union of the sheets' filters and templates, for the cache key
+	all := map[string]string{}
+	params := map[string]string{"format": formatXLSX}
+	var templates []string
+	for _, sh := range workbookSheets {
+		for k, v := range parseFilters(r, sh.Filters) {
+			all[k] = v
+			params["filter:"+k] = v
+		}
+		templates = append(templates, sh.Template)
+	}
+
+	cacheKey, err := s.reportCacheKey(cacheKeyInput{
+		Endpoint:  "report",
+		Project:   project,
+		Templates: templates,
+		Params:    params,
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// build renders every sheet; it runs detached from the client so it can
+	// also be used for background revalidation and shared between requests
+	var build func() (any, error)
+	build = func() (any, error) {
+		// Execute queries
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), 60*time.Second)
+		defer cancel()
+
+		modified := s.reportModified(ctx, project)
+
+		sheets := make([]xlsxSheet, 0, len(workbookSheets))
+		for _, sh := range workbookSheets {
+			q, err := sh.Query(s, project, parseFilters(r, sh.Filters))
+			if err != nil {
+				return nil, err
+			}
+			t, err := QueryTable(ctx, s.DB, q.SQL, q.Args...)
+			if err != nil {
+				return nil, errorStatus(http.StatusInternalServerError, "query failed (%s): %v", sh.Name, err)
+			}
+			sheets = append(sheets, xlsxSheet{Name: sh.Name, Table: t})
+		}
+
+		b, err := BuildXLSX(sheets, s.CSV)
+		if err != nil {
+			return nil, errorStatus(http.StatusInternalServerError, "failed to build workbook: %v", err)
+		}
+
+		// Set cache
+		p := reportPayload{Data: b, Modified: modified}
+		s.storeReport(cacheKey, p, build)
+		return p, nil
+	}
+
+	s.serveCached(w, r, cachedReport{
+		Spec:    reportSpec{Name: "report", Project: project, Filters: all},
+		Format:  formatXLSX,
+		Key:     cacheKey,
+		Refresh: build,
+	})
+}
@@ -0,0 +1,544 @@
+package httpx
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"serumpun-data-api/internal/forecast"
+	"serumpun-data-api/internal/plane"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Cycle time report modes (?mode=)
+const (
+	cycleModePercentile = "percentile"
+	cycleModeHistogram  = "histogram"
+)
+
+// cycleTimeGroups maps ?group_by= to the group columns of cycle_time.sql
+var cycleTimeGroups = map[string]string{
+	"nama":     "nama, email, instansi, scope, kab_kota, bidang",
+	"bidang":   "bidang",
+	"kab_kota": "kab_kota",
+}
+
+// defaultCycleBuckets are the histogram bucket upper bounds in days
+var defaultCycleBuckets = []float64{1, 3, 7, 14, 30, 60}
+
+// maxCycleBuckets caps the number of bounds in ?buckets=
+const maxCycleBuckets = 20
+
+// forecastGroups maps ?group_by= to the group column of forecast.sql
+var forecastGroups = map[string]string{
+	"bidang":   "bidang",
+	"kab_kota": "kab_kota",
+}
+
+// Forecast parameters: throughput history (?weeks=) and simulation trials (?trials=)
+const (
+	defaultForecastWeeks  = 12
+	minForecastWeeks      = 4
+	maxForecastWeeks      = 52
+	defaultForecastTrials = 10000
+	minForecastTrials     = 1000
+	maxForecastTrials     = 50000
+)
+
+// BurnupTemplate handles the daily burnup/burndown series using SQL template
+func (s *Server) BurnupTemplate(w http.ResponseWriter, r *http.Request) {
+	// Parse query parameters for filtering
+	filters := parseFilters(r, burnupFilters)
+	options, err := parseDateRange(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// Resolve Plane project (?project=, default from config)
+	project, ok := s.requestProject(w, r)
+	if !ok {
+		return
+	}
+
+	s.serveReport(w, r, reportSpec{
+		Name:      "burnup",
+		Project:   project,
+		Filters:   filters,
+		Options:   options,
+		Templates: []string{"burnup.sql"},
+		Build: func() (sqlQuery, error) {
+			return s.burnupQuery(project, filters, options)
+		},
+	})
+}
+
+// CFDTemplate handles the Cumulative Flow Diagram using SQL template
+func (s *Server) CFDTemplate(w http.ResponseWriter, r *http.Request) {
+	// Parse query parameters for filtering
+	filters := parseFilters(r, cfdFilters)
+	options, err := parseDateRange(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// Resolve Plane project (?project=, default from config)
+	project, ok := s.requestProject(w, r)
+	if !ok {
+		return
+	}
+
+	s.serveReport(w, r, reportSpec{
+		Name:      "cfd",
+		Project:   project,
+		Filters:   filters,
+		Options:   options,
+		Templates: []string{"cfd.sql"},
+		Build: func() (sqlQuery, error) {
+			return s.flowQuery("cfd.sql", cfdFilters, project, filters, options)
+		},
+	})
+}
+
+// CycleTimeTemplate handles cycle/lead time percentiles and histograms using
+// SQL template
+func (s *Server) CycleTimeTemplate(w http.ResponseWriter, r *http.Request) {
+	// Parse query parameters for filtering
+	filters := parseFilters(r, cycleTimeFilters)
+	options, err := parseCycleTimeOptions(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// Resolve Plane project (?project=, default from config)
+	project, ok := s.requestProject(w, r)
+	if !ok {
+		return
+	}
+
+	s.serveReport(w, r, reportSpec{
+		Name:      "cycle_time",
+		Project:   project,
+		Filters:   filters,
+		Options:   options,
+		Templates: []string{"cycle_time.sql"},
+		Build: func() (sqlQuery, error) {
+			return s.cycleTimeQuery(project, filters, options)
+		},
+	})
+}
+
+// parseCycleTimeOptions reads the date range (on completed_at), ?mode=,
+// ?group_by= and, for histograms, ?buckets=
+func parseCycleTimeOptions(r *http.Request) (map[string]string, error) {
+	options, err := parseDateRange(r)
+	if err != nil {
+		return nil, err
+	}
+	q := r.URL.Query()
+
+	mode := strings.ToLower(strings.TrimSpace(q.Get("mode")))
+	switch mode {
+	case "":
+		mode = cycleModePercentile
+	case cycleModePercentile, cycleModeHistogram:
+	default:
+		return nil, errorStatus(http.StatusBadRequest, "unsupported mode %q (supported: percentile, histogram)", mode)
+	}
+	options["mode"] = mode
+
+	groupBy := strings.ToLower(strings.TrimSpace(q.Get("group_by")))
+	if groupBy == "" {
+		groupBy = "nama"
+	}
+	if _, ok := cycleTimeGroups[groupBy]; !ok {
+		return nil, errorStatus(http.StatusBadRequest, "unsupported group_by %q (supported: nama, bidang, kab_kota)", groupBy)
+	}
+	options["group_by"] = groupBy
+
+	if mode == cycleModeHistogram {
+		bounds, err := parseBuckets(q.Get("buckets"))
+		if err != nil {
+			return nil, err
+		}
+		options["buckets"] = formatBuckets(bounds)
+	}
+	return options, nil
+}
+
+// parseBuckets reads comma-separated bucket upper bounds in days, e.g.
+// "1,3,7,14,30". They must be positive and increasing; empty is the default.
+func parseBuckets(v string) ([]float64, error) {
+	if strings.TrimSpace(v) == "" {
+		return defaultCycleBuckets, nil
+	}
+	parts := strings.Split(v, ",")
+	if len(parts) > maxCycleBuckets {
+		return nil, errorStatus(http.StatusBadRequest, "too many buckets (max %d)", maxCycleBuckets)
+	}
+	bounds := make([]float64, 0, len(parts))
+	for _, p := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil || f <= 0 || math.IsInf(f, 0) || math.IsNaN(f) {
+			return nil, errorStatus(http.StatusBadRequest, "invalid bucket %q (expected a positive number of days)", strings.TrimSpace(p))
+		}
+		if n := len(bounds); n > 0 && f <= bounds[n-1] {
+			return nil, errorStatus(http.StatusBadRequest, "buckets must be increasing (%g after %g)", f, bounds[n-1])
+		}
+		bounds = append(bounds, f)
+	}
+	return bounds, nil
+}
+
+// formatBuckets is the canonical form of bucket bounds, used in the cache key
+func formatBuckets(bounds []float64) string {
+	parts := make([]string, len(bounds))
+	for i, b := range bounds {
+		parts[i] = formatDays(b)
+	}
+	return strings.Join(parts, ",")
+}
+
+func formatDays(d float64) string {
+	return strconv.FormatFloat(d, 'f', -1, 64)
+}
+
+// bindBuckets binds the histogram buckets as (label, from, to) arrays for
+// cycle_time.sql: "0-1", "1-3", ..., and an open ended "60+"
+func bindBuckets(b *queryBuilder, bounds []float64) string {
+	labels := make([]string, 0, len(bounds)+1)
+	from := make([]float64, 0, len(bounds)+1)
+	to := make([]float64, 0, len(bounds)+1)
+	lower := 0.0
+	for _, upper := range bounds {
+		labels = append(labels, formatDays(lower)+"-"+formatDays(upper))
+		from = append(from, lower)
+		to = append(to, upper)
+		lower = upper
+	}
+	labels = append(labels, formatDays(lower)+"+")
+	from = append(from, lower)
+	to = append(to, math.Inf(1))
+	return b.arg(labels) + "::text[], " + b.arg(from) + "::float8[], " + b.arg(to) + "::float8[]"
+}
+
+// cycleTimeQuery renders the Cycle Time template for a project, filters and options
+func (s *Server) cycleTimeQuery(project plane.Project, filters, options map[string]string) (sqlQuery, error) {
+	// Current directory version (loaded at startup, hot-reloaded)
+	dir := s.Directory.Current()
+
+	// Load SQL template
+	sqlTemplate, err := s.Queries.Load("cycle_time.sql")
+	if err != nil {
+		return sqlQuery{}, errorStatus(http.StatusInternalServerError, "failed to load sql template: %v", err)
+	}
+
+	// Group columns and result come from whitelists, never from the request
+	groupColumns, ok := cycleTimeGroups[options["group_by"]]
+	if !ok {
+		return sqlQuery{}, errorStatus(http.StatusBadRequest, "unsupported group_by %q", options["group_by"])
+	}
+	result, orderBy := "percentiles", groupColumns
+	bounds := defaultCycleBuckets
+	if options["mode"] == cycleModeHistogram {
+		result, orderBy = "histogram", groupColumns+", metric, bucket_from"
+		if bounds, err = parseBuckets(options["buckets"]); err != nil {
+			return sqlQuery{}, err
+		}
+	}
+
+	// Build dynamic parts
+	qb := newQueryBuilder(project)
+	directory := buildDirectoryArrays(qb, dir.All(), s.Regions)
+	wilayah := buildRegionArrays(qb, s.Regions)
+	kodePattern := qb.textArg(s.Regions.KodePattern())
+	whereClause, err := buildWhereClause(qb, cycleTimeFilters, filters)
+	if err != nil {
+		return sqlQuery{}, errorStatus(http.StatusBadRequest, "%v", err)
+	}
+	fromDate := qb.dateArg(options["from"])
+	toDate := qb.dateArg(options["to"])
+	buckets := bindBuckets(qb, bounds)
+
+	// Replace placeholders
+	return qb.build(sqlTemplate, map[string]string{
+		"{{DIRECTORY}}":     directory,
+		"{{WILAYAH}}":       wilayah,
+		"{{KODE_PATTERN}}":  kodePattern,
+		"{{WHERE_CLAUSE}}":  whereClause,
+		"{{FROM_DATE}}":     fromDate,
+		"{{TO_DATE}}":       toDate,
+		"{{BUCKETS}}":       buckets,
+		"{{GROUP_COLUMNS}}": groupColumns,
+		"{{RESULT}}":        result,
+		"{{ORDER_BY}}":      orderBy,
+	}), nil
+}
+
+// ForecastTemplate handles the Monte Carlo completion forecast. The SQL
+// template only collects throughput and open issues; the simulation runs in Go.
+func (s *Server) ForecastTemplate(w http.ResponseWriter, r *http.Request) {
+	// Parse query parameters for filtering
+	filters := parseFilters(r, forecastFilters)
+	options, err := parseForecastOptions(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// Resolve Plane project (?project=, default from config)
+	project, ok := s.requestProject(w, r)
+	if !ok {
+		return
+	}
+
+	s.serveReport(w, r, reportSpec{
+		Name:      "forecast",
+		Project:   project,
+		Filters:   filters,
+		Options:   options,
+		Templates: []string{"forecast.sql"},
+		Compute: func(ctx context.Context) (*resultTable, error) {
+			return s.computeForecast(ctx, project, filters, options)
+		},
+	})
+}
+
+// parseForecastOptions reads ?group_by=, ?weeks= and ?trials=
+func parseForecastOptions(r *http.Request) (map[string]string, error) {
+	q := r.URL.Query()
+	options := map[string]string{}
+
+	groupBy := strings.ToLower(strings.TrimSpace(q.Get("group_by")))
+	if groupBy == "" {
+		groupBy = "bidang"
+	}
+	if _, ok := forecastGroups[groupBy]; !ok {
+		return nil, errorStatus(http.StatusBadRequest, "unsupported group_by %q (supported: bidang, kab_kota)", groupBy)
+	}
+	options["group_by"] = groupBy
+
+	weeks, err := intOption(q.Get("weeks"), "weeks", defaultForecastWeeks, minForecastWeeks, maxForecastWeeks)
+	if err != nil {
+		return nil, err
+	}
+	options["weeks"] = strconv.Itoa(weeks)
+
+	trials, err := intOption(q.Get("trials"), "trials", defaultForecastTrials, minForecastTrials, maxForecastTrials)
+	if err != nil {
+		return nil, err
+	}
+	options["trials"] = strconv.Itoa(trials)
+	return options, nil
+}
+
+// intOption parses an integer parameter within [lo, hi]; empty is def
+func intOption(v, name string, def, lo, hi int) (int, error) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < lo || n > hi {
+		return 0, errorStatus(http.StatusBadRequest, "invalid %s %q (expected %d-%d)", name, v, lo, hi)
+	}
+	return n, nil
+}
+
+// forecastQuery renders the Forecast template for a project, filters and options
+func (s *Server) forecastQuery(project plane.Project, filters, options map[string]string) (sqlQuery, error) {
+	// Current directory version (loaded at startup, hot-reloaded)
+	dir := s.Directory.Current()
+
+	// Load SQL template
+	sqlTemplate, err := s.Queries.Load("forecast.sql")
+	if err != nil {
+		return sqlQuery{}, errorStatus(http.StatusInternalServerError, "failed to load sql template: %v", err)
+	}
+
+	// Group column comes from a whitelist, never from the request
+	groupColumn, ok := forecastGroups[options["group_by"]]
+	if !ok {
+		return sqlQuery{}, errorStatus(http.StatusBadRequest, "unsupported group_by %q", options["group_by"])
+	}
+	weeks, err := strconv.Atoi(options["weeks"])
+	if err != nil {
+		return sqlQuery{}, errorStatus(http.StatusBadRequest, "invalid weeks %q", options["weeks"])
+	}
+
+	// Build dynamic parts
+	qb := newQueryBuilder(project)
+	directory := buildDirectoryArrays(qb, dir.All(), s.Regions)
+	wilayah := buildRegionArrays(qb, s.Regions)
+	kodePattern := qb.textArg(s.Regions.KodePattern())
+	whereClause, err := buildWhereClause(qb, forecastFilters, filters)
+	if err != nil {
+		return sqlQuery{}, errorStatus(http.StatusBadRequest, "%v", err)
+	}
+	weeksArg := qb.arg(weeks) + "::int"
+
+	// Replace placeholders
+	return qb.build(sqlTemplate, map[string]string{
+		"{{DIRECTORY}}":    directory,
+		"{{WILAYAH}}":      wilayah,
+		"{{KODE_PATTERN}}": kodePattern,
+		"{{WHERE_CLAUSE}}": whereClause,
+		"{{GROUP_COLUMN}}": groupColumn,
+		"{{WEEKS}}":        weeksArg,
+	}), nil
+}
+
+// computeForecast loads the throughput per group and simulates it
+func (s *Server) computeForecast(ctx context.Context, project plane.Project, filters, options map[string]string) (*resultTable, error) {
+	q, err := s.forecastQuery(project, filters, options)
+	if err != nil {
+		return nil, err
+	}
+	trials, err := strconv.Atoi(options["trials"])
+	if err != nil {
+		return nil, errorStatus(http.StatusBadRequest, "invalid trials %q", options["trials"])
+	}
+
+	rows, err := s.DB.Query(ctx, q.SQL, q.Args...)
+	if err != nil {
+		return nil, errorStatus(http.StatusInternalServerError, "query failed: %v", err)
+	}
+	defer rows.Close()
+
+	var inputs []forecast.Input
+	for rows.Next() {
+		var (
+			in     forecast.Input
+			remain int64
+			target pgtype.Date
+			weekly []int64
+		)
+		if err := rows.Scan(&in.Key, &remain, &target, &weekly); err != nil {
+			return nil, errorStatus(http.StatusInternalServerError, "query failed: %v", err)
+		}
+		in.Remaining = int(remain)
+		if target.Valid {
+			in.Target = target.Time
+		}
+		for _, n := range weekly {
+			in.Weekly = append(in.Weekly, int(n))
+		}
+		inputs = append(inputs, in)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errorStatus(http.StatusInternalServerError, "query failed: %v", err)
+	}
+
+	// Dates are calendar days in the report timezone
+	now := time.Now().In(s.CSV.location())
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	t := &resultTable{
+		Columns: []string{options["group_by"], "remaining_issues", "avg_weekly_throughput", "target_date",
+			"p50_date", "p85_date", "p95_date", "prob_by_target", "forecast_status"},
+		OIDs: []uint32{pgtype.TextOID, pgtype.Int8OID, pgtype.Float8OID, pgtype.DateOID,
+			pgtype.DateOID, pgtype.DateOID, pgtype.DateOID, pgtype.Float8OID, pgtype.TextOID},
+	}
+	for _, in := range inputs {
+		res := forecast.Simulate(in, trials, today)
+
+		total := 0
+		for _, n := range in.Weekly {
+			total += n
+		}
+		avg := 0.0
+		if len(in.Weekly) > 0 {
+			avg = float64(total) / float64(len(in.Weekly))
+		}
+		var prob any
+		if res.ProbByTarget >= 0 {
+			prob = math.Round(res.ProbByTarget*1000) / 10
+		}
+
+		t.Rows = append(t.Rows, []any{
+			in.Key,
+			int64(in.Remaining),
+			avg,
+			dateOrNil(in.Target),
+			dateOrNil(res.P50),
+			dateOrNil(res.P85),
+			dateOrNil(res.P95),
+			prob,
+			forecastStatus(in, res, total),
+		})
+	}
+	return t, nil
+}
+
+// forecastStatus summarises a forecast: done, no throughput, no target, or
+// on track / at risk / off track by the probability of meeting the target
+func forecastStatus(in forecast.Input, res forecast.Result, throughput int) string {
+	switch {
+	case in.Remaining == 0:
+		return "Selesai"
+	case throughput == 0:
+		return "Tidak Ada Throughput"
+	case res.ProbByTarget < 0:
+		return "Tanpa Target"
+	case res.ProbByTarget >= 0.85:
+		return "On Track"
+	case res.ProbByTarget >= 0.5:
+		return "At Risk"
+	}
+	return "Off Track"
+}
+
+// dateOrNil is a date cell, NULL for the zero time
+func dateOrNil(t time.Time) any {
+	if t.IsZero() {
+		return nil
+	}
+	return t
+}
+
+// burnupQuery renders the Burnup template for a project, filters and date range
+func (s *Server) burnupQuery(project plane.Project, filters, options map[string]string) (sqlQuery, error) {
+	return s.flowQuery("burnup.sql", burnupFilters, project, filters, options)
+}
+
+// flowQuery renders a flow metric template. These templates share the
+// issue attribution of timeline.sql (directory, wilayah, kode pattern), apply
+// the filters before building their series and take an optional date range.
+func (s *Server) flowQuery(name string, allowed filterColumns, project plane.Project, filters, options map[string]string) (sqlQuery, error) {
+	// Current directory version (loaded at startup, hot-reloaded)
+	dir := s.Directory.Current()
+
+	// Load SQL template
+	sqlTemplate, err := s.Queries.Load(name)
+	if err != nil {
+		return sqlQuery{}, errorStatus(http.StatusInternalServerError, "failed to load sql template: %v", err)
+	}
+
+	// Build dynamic parts
+	qb := newQueryBuilder(project)
+	directory := buildDirectoryArrays(qb, dir.All(), s.Regions)
+	wilayah := buildRegionArrays(qb, s.Regions)
+	kodePattern := qb.textArg(s.Regions.KodePattern())
+	whereClause, err := buildWhereClause(qb, allowed, filters)
+	if err != nil {
+		return sqlQuery{}, errorStatus(http.StatusBadRequest, "%v", err)
+	}
+	fromDate := qb.dateArg(options["from"])
+	toDate := qb.dateArg(options["to"])
+
+	// Replace placeholders
+	return qb.build(sqlTemplate, map[string]string{
+		"{{DIRECTORY}}":    directory,
+		"{{WILAYAH}}":      wilayah,
+		"{{KODE_PATTERN}}": kodePattern,
+		"{{WHERE_CLAUSE}}": whereClause,
+		"{{FROM_DATE}}":    fromDate,
+		"{{TO_DATE}}":      toDate,
+	}), nil
+}
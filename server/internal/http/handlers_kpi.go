@@ -1,226 +1,184 @@
 package httpx
 
 import (
-	"context"
 	"net/http"
-	"os"
-	"time"
+
+	"serumpun-data-api/internal/plane"
 )
 
 // KPIProvinsiTemplate handles KPI Provinsi using SQL template
 func (s *Server) KPIProvinsiTemplate(w http.ResponseWriter, r *http.Request) {
 	// Parse query parameters for filtering
-	filters := map[string]string{
-		"bidang":  r.URL.Query().Get("bidang"),
-		"jabatan": r.URL.Query().Get("jabatan"),
+	filters := parseFilters(r, kpiProvinsiFilters)
+	options, err := parseCompare(r)
+	if err != nil {
+		writeError(w, err)
+		return
 	}
 
-	// Build cache key
-	cacheKey := buildCacheKey("kpi_provinsi", filters)
-
-	// Check cache
-	if b, ok := s.Cache.Get(cacheKey); ok {
-		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
-		w.Header().Set("Cache-Control", "public, max-age=30")
-		w.WriteHeader(http.StatusOK)
-		_, _ = w.Write(b)
+	// Resolve Plane project (?project=, default from config)
+	project, ok := s.requestProject(w, r)
+	if !ok {
 		return
 	}
 
-	// Load directory from CSV
-	csvPath := os.Getenv("DIRECTORY_CSV_PATH")
-	if csvPath == "" {
-		csvPath = "data/daftar_pengguna_serumpun.csv"
-	}
+	// ?compare=7d adds previous-value and delta columns from the snapshots
+	s.serveReport(w, r, s.withComparison(reportSpec{
+		Name:      "kpi_provinsi",
+		Project:   project,
+		Filters:   filters,
+		Options:   options,
+		Templates: []string{"kpi_provinsi.sql"},
+		Build: func() (sqlQuery, error) {
+			return s.kpiProvinsiQuery(project, filters)
+		},
+	}))
+}
 
-	dir, err := LoadDirectoryFromCSV(csvPath)
+// KPIKabkotTemplate handles KPI Kabkot using SQL template
+func (s *Server) KPIKabkotTemplate(w http.ResponseWriter, r *http.Request) {
+	// Parse query parameters for filtering
+	filters := parseFilters(r, kpiKabkotFilters)
+	options, err := parseCompare(r)
 	if err != nil {
-		http.Error(w, "failed to load directory: "+err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 
-	if len(dir.Provinsi) == 0 {
-		http.Error(w, "no provinsi staff found in directory", http.StatusInternalServerError)
+	// Resolve Plane project (?project=, default from config)
+	project, ok := s.requestProject(w, r)
+	if !ok {
 		return
 	}
 
-	// Load SQL template
-	sqlTemplate, err := s.Queries.Load("kpi_provinsi.sql")
+	// ?compare=7d adds previous-value and delta columns from the snapshots
+	s.serveReport(w, r, s.withComparison(reportSpec{
+		Name:      "kpi_kabkot",
+		Project:   project,
+		Filters:   filters,
+		Options:   options,
+		Templates: []string{"kpi_kabkot.sql"},
+		Build: func() (sqlQuery, error) {
+			return s.kpiKabkotQuery(project, filters)
+		},
+	}))
+}
+
+// HeatmapTemplate handles Heatmap using SQL template
+func (s *Server) HeatmapTemplate(w http.ResponseWriter, r *http.Request) {
+	// Parse query parameters for filtering
+	filters := parseFilters(r, heatmapFilters)
+	options, err := parseCompare(r)
 	if err != nil {
-		http.Error(w, "failed to load sql template: "+err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 
-	// Build dynamic parts
-	namaCases := buildNamaCases(dir.Provinsi)
-	bidangCases := buildBidangCases(dir.Provinsi)
-	jabatanCases := buildJabatanCases(dir.Provinsi)
-	emails := buildEmailList(dir.Provinsi)
-	whereClause := buildAdditionalWhere(filters)
-
-	// Replace placeholders
-	sql := buildDynamicSQL(sqlTemplate, map[string]string{
-		"{{NAMA_CASES}}":    namaCases,
-		"{{BIDANG_CASES}}":  bidangCases,
-		"{{JABATAN_CASES}}": jabatanCases,
-		"{{EMAILS}}":        emails,
-		"{{WHERE_CLAUSE}}":  whereClause,
-	})
-
-	// Execute query
-	ctx, cancel := context.WithTimeout(r.Context(), 25*time.Second)
-	defer cancel()
-
-	b, err := QueryToCSV(ctx, s.DB, sql)
-	if err != nil {
-		http.Error(w, "query failed: "+err.Error(), http.StatusInternalServerError)
+	// Resolve Plane project (?project=, default from config)
+	project, ok := s.requestProject(w, r)
+	if !ok {
 		return
 	}
 
-	// Set cache
-	s.Cache.Set(cacheKey, b)
-
-	// Write response
-	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
-	w.Header().Set("Cache-Control", "public, max-age=30")
-	w.WriteHeader(http.StatusOK)
-	_, _ = w.Write(b)
+	// ?compare=7d adds previous-value and delta columns from the snapshots
+	s.serveReport(w, r, s.withComparison(reportSpec{
+		Name:      "heatmap",
+		Project:   project,
+		Filters:   filters,
+		Options:   options,
+		Templates: []string{"heatmap.sql"},
+		Build: func() (sqlQuery, error) {
+			return s.heatmapQuery(project, filters)
+		},
+	}))
 }
 
-// KPIKabkotTemplate handles KPI Kabkot using SQL template
-func (s *Server) KPIKabkotTemplate(w http.ResponseWriter, r *http.Request) {
-	// Parse query parameters for filtering
-	filters := map[string]string{
-		"bidang":   r.URL.Query().Get("bidang"),
-		"instansi": r.URL.Query().Get("instansi"),
-		"jabatan":  r.URL.Query().Get("jabatan"),
-	}
-
-	// Build cache key
-	cacheKey := buildCacheKey("kpi_kabkot", filters)
+// kpiProvinsiQuery renders the KPI Provinsi template for a project and filters
+func (s *Server) kpiProvinsiQuery(project plane.Project, filters map[string]string) (sqlQuery, error) {
+	// Current directory version (loaded at startup, hot-reloaded)
+	dir := s.Directory.Current()
 
-	// Check cache
-	if b, ok := s.Cache.Get(cacheKey); ok {
-		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
-		w.Header().Set("Cache-Control", "public, max-age=30")
-		w.WriteHeader(http.StatusOK)
-		_, _ = w.Write(b)
-		return
+	if len(dir.Provinsi) == 0 {
+		return sqlQuery{}, errorStatus(http.StatusInternalServerError, "no provinsi staff found in directory")
 	}
 
-	// Load directory from CSV
-	csvPath := os.Getenv("DIRECTORY_CSV_PATH")
-	if csvPath == "" {
-		csvPath = "data/daftar_pengguna_serumpun.csv"
+	// Load SQL template
+	sqlTemplate, err := s.Queries.Load("kpi_provinsi.sql")
+	if err != nil {
+		return sqlQuery{}, errorStatus(http.StatusInternalServerError, "failed to load sql template: %v", err)
 	}
 
-	dir, err := LoadDirectoryFromCSV(csvPath)
+	// Build dynamic parts
+	qb := newQueryBuilder(project)
+	directory := buildDirectoryArrays(qb, dir.Provinsi, s.Regions)
+	whereClause, err := buildAdditionalWhere(qb, kpiProvinsiFilters, filters)
 	if err != nil {
-		http.Error(w, "failed to load directory: "+err.Error(), http.StatusInternalServerError)
-		return
+		return sqlQuery{}, errorStatus(http.StatusBadRequest, "%v", err)
 	}
 
+	// Replace placeholders
+	return qb.build(sqlTemplate, map[string]string{
+		"{{DIRECTORY}}":    directory,
+		"{{WHERE_CLAUSE}}": whereClause,
+	}), nil
+}
+
+// kpiKabkotQuery renders the KPI Kabkot template for a project and filters
+func (s *Server) kpiKabkotQuery(project plane.Project, filters map[string]string) (sqlQuery, error) {
+	// Current directory version (loaded at startup, hot-reloaded)
+	dir := s.Directory.Current()
+
 	if len(dir.Kabkot) == 0 {
-		http.Error(w, "no ketua kabkot found in directory", http.StatusInternalServerError)
-		return
+		return sqlQuery{}, errorStatus(http.StatusInternalServerError, "no ketua kabkot found in directory")
 	}
 
 	// Load SQL template
 	sqlTemplate, err := s.Queries.Load("kpi_kabkot.sql")
 	if err != nil {
-		http.Error(w, "failed to load sql template: "+err.Error(), http.StatusInternalServerError)
-		return
+		return sqlQuery{}, errorStatus(http.StatusInternalServerError, "failed to load sql template: %v", err)
 	}
 
 	// Build dynamic parts
-	namaCases := buildNamaCases(dir.Kabkot)
-	bidangCases := buildBidangCases(dir.Kabkot)
-	instansiCases := buildInstansiCases(dir.Kabkot)
-	jabatanCases := buildJabatanCases(dir.Kabkot)
-	emails := buildEmailList(dir.Kabkot)
-	whereClause := buildAdditionalWhere(filters)
-
-	// Replace placeholders
-	sql := buildDynamicSQL(sqlTemplate, map[string]string{
-		"{{NAMA_CASES}}":     namaCases,
-		"{{BIDANG_CASES}}":   bidangCases,
-		"{{INSTANSI_CASES}}": instansiCases,
-		"{{JABATAN_CASES}}":  jabatanCases,
-		"{{EMAILS}}":         emails,
-		"{{WHERE_CLAUSE}}":   whereClause,
-	})
-
-	// Execute query
-	ctx, cancel := context.WithTimeout(r.Context(), 25*time.Second)
-	defer cancel()
-
-	b, err := QueryToCSV(ctx, s.DB, sql)
+	qb := newQueryBuilder(project)
+	directory := buildDirectoryArrays(qb, dir.Kabkot, s.Regions)
+	whereClause, err := buildAdditionalWhere(qb, kpiKabkotFilters, filters)
 	if err != nil {
-		http.Error(w, "query failed: "+err.Error(), http.StatusInternalServerError)
-		return
+		return sqlQuery{}, errorStatus(http.StatusBadRequest, "%v", err)
 	}
 
-	// Set cache
-	s.Cache.Set(cacheKey, b)
-
-	// Write response
-	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
-	w.Header().Set("Cache-Control", "public, max-age=30")
-	w.WriteHeader(http.StatusOK)
-	_, _ = w.Write(b)
+	// Replace placeholders
+	return qb.build(sqlTemplate, map[string]string{
+		"{{DIRECTORY}}":    directory,
+		"{{WHERE_CLAUSE}}": whereClause,
+	}), nil
 }
 
-// HeatmapTemplate handles Heatmap using SQL template
-func (s *Server) HeatmapTemplate(w http.ResponseWriter, r *http.Request) {
-	// Parse query parameters for filtering
-	filters := map[string]string{
-		"kab_kota": r.URL.Query().Get("kab_kota"),
-		"bidang":   r.URL.Query().Get("bidang"),
-	}
-
-	// Build cache key
-	cacheKey := buildCacheKey("heatmap", filters)
-
-	// Check cache
-	if b, ok := s.Cache.Get(cacheKey); ok {
-		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
-		w.Header().Set("Cache-Control", "public, max-age=30")
-		w.WriteHeader(http.StatusOK)
-		_, _ = w.Write(b)
-		return
-	}
+// heatmapQuery renders the Heatmap template for a project and filters
+func (s *Server) heatmapQuery(project plane.Project, filters map[string]string) (sqlQuery, error) {
+	// Current directory version (loaded at startup, hot-reloaded)
+	dir := s.Directory.Current()
 
 	// Load SQL template
 	sqlTemplate, err := s.Queries.Load("heatmap.sql")
 	if err != nil {
-		http.Error(w, "failed to load sql template: "+err.Error(), http.StatusInternalServerError)
-		return
+		return sqlQuery{}, errorStatus(http.StatusInternalServerError, "failed to load sql template: %v", err)
 	}
 
-	// Build HAVING clause for filters
-	havingClause := buildHavingClause(filters)
-
-	// Replace placeholders
-	sql := buildDynamicSQL(sqlTemplate, map[string]string{
-		"{{HAVING_CLAUSE}}": havingClause,
-	})
-
-	// Execute query
-	ctx, cancel := context.WithTimeout(r.Context(), 25*time.Second)
-	defer cancel()
-
-	b, err := QueryToCSV(ctx, s.DB, sql)
+	// Build dynamic parts
+	qb := newQueryBuilder(project)
+	directory := buildDirectoryArrays(qb, dir.All(), s.Regions)
+	wilayah := buildRegionArrays(qb, s.Regions)
+	kodePattern := qb.textArg(s.Regions.KodePattern())
+	havingClause, err := buildHavingClause(qb, heatmapFilters, filters)
 	if err != nil {
-		http.Error(w, "query failed: "+err.Error(), http.StatusInternalServerError)
-		return
+		return sqlQuery{}, errorStatus(http.StatusBadRequest, "%v", err)
 	}
 
-	// Set cache
-	s.Cache.Set(cacheKey, b)
-
-	// Write response
-	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
-	w.Header().Set("Cache-Control", "public, max-age=30")
-	w.WriteHeader(http.StatusOK)
-	_, _ = w.Write(b)
+	// Replace placeholders
+	return qb.build(sqlTemplate, map[string]string{
+		"{{DIRECTORY}}":     directory,
+		"{{WILAYAH}}":       wilayah,
+		"{{KODE_PATTERN}}":  kodePattern,
+		"{{HAVING_CLAUSE}}": havingClause,
+	}), nil
 }
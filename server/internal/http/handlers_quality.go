@@ -0,0 +1,339 @@
+package httpx
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+
+	"serumpun-data-api/internal/plane"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// DataQualityTemplate handles the data-quality audit using SQL template
+func (s *Server) DataQualityTemplate(w http.ResponseWriter, r *http.Request) {
+	// Parse query parameters for filtering
+	filters := parseFilters(r, dataQualityFilters)
+
+	// Resolve Plane project (?project=, default from config)
+	project, ok := s.requestProject(w, r)
+	if !ok {
+		return
+	}
+
+	s.serveReport(w, r, reportSpec{
+		Name:      "data_quality",
+		Project:   project,
+		Filters:   filters,
+		Templates: []string{"data_quality.sql"},
+		Build: func() (sqlQuery, error) {
+			return s.dataQualityQuery(project, filters)
+		},
+	})
+}
+
+// dataQualityQuery renders the Data Quality template for a project and filters
+func (s *Server) dataQualityQuery(project plane.Project, filters map[string]string) (sqlQuery, error) {
+	// Current directory version (loaded at startup, hot-reloaded)
+	dir := s.Directory.Current()
+
+	// Load SQL template
+	sqlTemplate, err := s.Queries.Load("data_quality.sql")
+	if err != nil {
+		return sqlQuery{}, errorStatus(http.StatusInternalServerError, "failed to load sql template: %v", err)
+	}
+
+	// Build dynamic parts
+	qb := newQueryBuilder(project)
+	directory := buildDirectoryArrays(qb, dir.All(), s.Regions)
+	memberEmails := make([]string, len(dir.Members))
+	for i, m := range dir.Members {
+		memberEmails[i] = m.Email
+	}
+	members := bindTextArrays(qb, memberEmails)
+	wilayah := buildRegionArrays(qb, s.Regions)
+	kodePattern := qb.textArg(s.Regions.KodePattern())
+	whereClause, err := buildWhereClause(qb, dataQualityFilters, filters)
+	if err != nil {
+		return sqlQuery{}, errorStatus(http.StatusBadRequest, "%v", err)
+	}
+
+	// Replace placeholders
+	return qb.build(sqlTemplate, map[string]string{
+		"{{DIRECTORY}}":    directory,
+		"{{MEMBERS}}":      members,
+		"{{WILAYAH}}":      wilayah,
+		"{{KODE_PATTERN}}": kodePattern,
+		"{{WHERE_CLAUSE}}": whereClause,
+	}), nil
+}
+
+// Directory reconciliation reasons, in output order
+const (
+	reasonNoPlaneAccount  = "no_plane_account"
+	reasonNotInDirectory  = "not_in_directory"
+	reasonEmailMismatch   = "email_mismatch"
+	reasonJoinedNotMember = "joined_not_member"
+	reasonMemberNotJoined = "member_not_joined"
+)
+
+var reconciliationReasons = []string{
+	reasonNoPlaneAccount,
+	reasonNotInDirectory,
+	reasonEmailMismatch,
+	reasonJoinedNotMember,
+	reasonMemberNotJoined,
+}
+
+var reconciliationKeterangan = map[string]string{
+	reasonNoPlaneAccount:  "Email directory tidak punya akun Plane",
+	reasonNotInDirectory:  "Anggota proyek Plane tidak ada di directory",
+	reasonEmailMismatch:   "Email berbeda huruf besar/kecil atau spasi",
+	reasonJoinedNotMember: "Join Proyek \"Sudah\" tapi bukan anggota proyek",
+	reasonMemberNotJoined: "Anggota proyek tapi Join Proyek bukan \"Sudah\"",
+}
+
+// DirectoryReconciliation compares the directory CSV with Plane accounts and
+// project membership
+func (s *Server) DirectoryReconciliation(w http.ResponseWriter, r *http.Request) {
+	// Parse query parameters for filtering
+	filters := parseFilters(r, reconciliationFilters)
+	if v := filters["reason"]; v != "" {
+		if _, ok := reconciliationKeterangan[v]; !ok {
+			writeError(w, errorStatus(http.StatusBadRequest, "unsupported reason %q (supported: %s)", v, strings.Join(reconciliationReasons, ", ")))
+			return
+		}
+	}
+
+	// Resolve Plane project (?project=, default from config)
+	project, ok := s.requestProject(w, r)
+	if !ok {
+		return
+	}
+
+	s.serveReport(w, r, reportSpec{
+		Name:      "directory_reconciliation",
+		Project:   project,
+		Filters:   filters,
+		Templates: []string{"map_users_by_email.sql", "project_members.sql"},
+		Compute: func(ctx context.Context) (*resultTable, error) {
+			return s.computeReconciliation(ctx, project, filters)
+		},
+	})
+}
+
+// reconciliationRow is one finding before it is rendered
+type reconciliationRow struct {
+	reason, detail string
+	member         *DirectoryMember
+	plane          *ProjectMember
+}
+
+// computeReconciliation matches every directory member to a Plane account
+// (MapUsersByEmail, case-insensitive) and to the project's members. Plane
+// emails that only match after trimming are reported as mismatches instead
+// of missing accounts, since the report SQL joins on LOWER(email) only.
+func (s *Server) computeReconciliation(ctx context.Context, project plane.Project, filters map[string]string) (*resultTable, error) {
+	members := s.Directory.Current().Members
+
+	emails := make([]string, 0, len(members))
+	seen := make(map[string]bool, len(members))
+	for _, m := range members {
+		if !seen[m.Email] {
+			seen[m.Email] = true
+			emails = append(emails, m.Email)
+		}
+	}
+	users, err := s.MapUsersByEmail(ctx, emails)
+	if err != nil {
+		return nil, errorStatus(http.StatusInternalServerError, "query failed: %v", err)
+	}
+	projectMembers, err := s.ProjectMembers(ctx, project.WorkspaceID, project.ProjectID)
+	if err != nil {
+		return nil, errorStatus(http.StatusInternalServerError, "query failed: %v", err)
+	}
+
+	byUser := make(map[string]*ProjectMember, len(projectMembers))
+	byEmail := make(map[string]*ProjectMember, len(projectMembers))
+	for i := range projectMembers {
+		pm := &projectMembers[i]
+		byUser[pm.UserID] = pm
+		byEmail[normEmail(pm.Email)] = pm
+	}
+
+	var found []reconciliationRow
+	add := func(reason, detail string, m *DirectoryMember, pm *ProjectMember) {
+		found = append(found, reconciliationRow{reason: reason, detail: detail, member: m, plane: pm})
+	}
+
+	for i := range members {
+		m := &members[i]
+		if m.RawEmail != m.Email {
+			add(reasonEmailMismatch, fmt.Sprintf("directory: %q", m.RawEmail), m, nil)
+		}
+
+		pm := byUser[users[m.Email]]
+		if userID, ok := users[m.Email]; !ok {
+			pm = byEmail[m.Email]
+			if pm == nil {
+				add(reasonNoPlaneAccount, m.Email, m, nil)
+			}
+		} else if pm == nil {
+			pm = &ProjectMember{UserID: userID}
+		}
+		if pm != nil && pm.Email != "" && pm.Email != m.Email && pm.Email != m.RawEmail {
+			add(reasonEmailMismatch, fmt.Sprintf("plane: %q", pm.Email), m, pm)
+		}
+
+		isMember := pm != nil && pm.Active && byUser[pm.UserID] != nil
+		joined := isSudah(m.JoinProyek)
+		switch {
+		case joined && !isMember:
+			add(reasonJoinedNotMember, joinStatus(m), m, pm)
+		case !joined && isMember:
+			add(reasonMemberNotJoined, joinStatus(m), m, pm)
+		}
+	}
+
+	for i := range projectMembers {
+		pm := &projectMembers[i]
+		if pm.Active && !seen[normEmail(pm.Email)] {
+			add(reasonNotInDirectory, pm.DisplayName, nil, pm)
+		}
+	}
+
+	order := make(map[string]int, len(reconciliationReasons))
+	for i, reason := range reconciliationReasons {
+		order[reason] = i
+	}
+	sort.SliceStable(found, func(i, j int) bool {
+		return order[found[i].reason] < order[found[j].reason]
+	})
+
+	t := &resultTable{
+		Columns: []string{"reason", "keterangan", "nama", "email", "instansi", "jabatan",
+			"invitation", "join_proyek", "plane_email", "plane_user_id", "project_member", "detail", "csv_line"},
+		OIDs: []uint32{pgtype.TextOID, pgtype.TextOID, pgtype.TextOID, pgtype.TextOID, pgtype.TextOID, pgtype.TextOID,
+			pgtype.TextOID, pgtype.TextOID, pgtype.TextOID, pgtype.TextOID, pgtype.BoolOID, pgtype.TextOID, pgtype.Int8OID},
+	}
+	for _, f := range found {
+		if v := filters["reason"]; v != "" && f.reason != v {
+			continue
+		}
+		row := make([]any, len(t.Columns))
+		row[0], row[1], row[11] = f.reason, reconciliationKeterangan[f.reason], f.detail
+		if m := f.member; m != nil {
+			row[2], row[3], row[4], row[5], row[6], row[7] = m.Nama, m.Email, m.Instansi, m.Jabatan, m.Invitation, m.JoinProyek
+			row[12] = int64(m.Line)
+		}
+		row[10] = false
+		if pm := f.plane; pm != nil {
+			if pm.Email != "" {
+				row[8] = pm.Email
+			}
+			row[9] = pm.UserID
+			row[10] = pm.Active && byUser[pm.UserID] != nil
+		}
+		t.Rows = append(t.Rows, row)
+	}
+	return t, nil
+}
+
+// joinStatus describes a member's Invitation/Join Proyek cells
+func joinStatus(m *DirectoryMember) string {
+	return fmt.Sprintf("Invitation=%s, Join Proyek=%s", m.Invitation, m.JoinProyek)
+}
+
+// Onboarding views (?view=)
+const (
+	onboardingViewSummary = "summary"
+	onboardingViewList    = "list"
+)
+
+// onboardingOrder is the ORDER BY of each onboarding view
+var onboardingOrder = map[string]string{
+	onboardingViewSummary: "instansi, bidang",
+	onboardingViewList:    "instansi, bidang, status, nama",
+}
+
+// onboardingStatuses are the status values of the list view
+var onboardingStatuses = []string{"belum_diundang", "belum_join", "join_tanpa_issue", "aktif"}
+
+// OnboardingTemplate handles the onboarding status report using SQL template
+func (s *Server) OnboardingTemplate(w http.ResponseWriter, r *http.Request) {
+	// Parse query parameters for filtering
+	filters := parseFilters(r, onboardingFilters)
+	if v := filters["status"]; v != "" && !slices.Contains(onboardingStatuses, v) {
+		writeError(w, errorStatus(http.StatusBadRequest, "unsupported status %q (supported: %s)", v, strings.Join(onboardingStatuses, ", ")))
+		return
+	}
+
+	view := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("view")))
+	if view == "" {
+		view = onboardingViewSummary
+	}
+	if _, ok := onboardingOrder[view]; !ok {
+		writeError(w, errorStatus(http.StatusBadRequest, "unsupported view %q (supported: summary, list)", view))
+		return
+	}
+	options := map[string]string{"view": view}
+
+	// Resolve Plane project (?project=, default from config)
+	project, ok := s.requestProject(w, r)
+	if !ok {
+		return
+	}
+
+	s.serveReport(w, r, reportSpec{
+		Name:      "onboarding",
+		Project:   project,
+		Filters:   filters,
+		Options:   options,
+		Templates: []string{"onboarding.sql"},
+		Build: func() (sqlQuery, error) {
+			return s.onboardingQuery(project, filters, options)
+		},
+	})
+}
+
+// onboardingQuery renders the Onboarding template for a project, filters and view
+func (s *Server) onboardingQuery(project plane.Project, filters, options map[string]string) (sqlQuery, error) {
+	// Current directory version (loaded at startup, hot-reloaded)
+	dir := s.Directory.Current()
+
+	// Load SQL template
+	sqlTemplate, err := s.Queries.Load("onboarding.sql")
+	if err != nil {
+		return sqlQuery{}, errorStatus(http.StatusInternalServerError, "failed to load sql template: %v", err)
+	}
+
+	// Result and order come from the whitelist, never from the request
+	view := options["view"]
+	orderBy, ok := onboardingOrder[view]
+	if !ok {
+		return sqlQuery{}, errorStatus(http.StatusBadRequest, "unsupported view %q", view)
+	}
+	result := "summary"
+	if view == onboardingViewList {
+		result = "filtered"
+	}
+
+	// Build dynamic parts
+	qb := newQueryBuilder(project)
+	members := buildMemberArrays(qb, dir.Members)
+	whereClause, err := buildWhereClause(qb, onboardingFilters, filters)
+	if err != nil {
+		return sqlQuery{}, errorStatus(http.StatusBadRequest, "%v", err)
+	}
+
+	// Replace placeholders
+	return qb.build(sqlTemplate, map[string]string{
+		"{{MEMBERS}}":      members,
+		"{{WHERE_CLAUSE}}": whereClause,
+		"{{RESULT}}":       result,
+		"{{ORDER_BY}}":     orderBy,
+	}), nil
+}
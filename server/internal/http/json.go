@@ -0,0 +1,156 @@
+package httpx
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// jsonResult is the cacheable part of a JSON report; the metadata envelope
+// (cache status, filters) is added per response by renderJSON in report.go.
+type jsonResult struct {
+	Columns     []string        `json:"columns"`
+	Data        json.RawMessage `json:"data"`
+	RowCount    int             `json:"row_count"`
+	GeneratedAt time.Time       `json:"generated_at"`
+}
+
+// QueryToJSON runs the query and encodes every row as an object keyed by
+// column name, with typed values (numbers, ISO dates, null).
+func QueryToJSON(ctx context.Context, pool *pgxpool.Pool, sql string, args ...any) ([]byte, error) {
+	rows, err := pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	fields := rows.FieldDescriptions()
+	columns := make([]string, len(fields))
+	keys := make([][]byte, len(fields))
+	for i, f := range fields {
+		columns[i] = string(f.Name)
+		k, err := json.Marshal(columns[i])
+		if err != nil {
+			return nil, err
+		}
+		keys[i] = k
+	}
+
+	var data bytes.Buffer
+	data.WriteByte('[')
+	count := 0
+	for rows.Next() {
+		vals, err := rows.Values()
+		if err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			data.WriteByte(',')
+		}
+		data.WriteByte('{')
+		for i, v := range vals {
+			if i > 0 {
+				data.WriteByte(',')
+			}
+			data.Write(keys[i])
+			data.WriteByte(':')
+			b, err := json.Marshal(jsonValue(v, fields[i].DataTypeOID))
+			if err != nil {
+				return nil, fmt.Errorf("encode column %s: %w", columns[i], err)
+			}
+			data.Write(b)
+		}
+		data.WriteByte('}')
+		count++
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	data.WriteByte(']')
+
+	return json.Marshal(jsonResult{
+		Columns:     columns,
+		Data:        data.Bytes(),
+		RowCount:    count,
+		GeneratedAt: time.Now(),
+	})
+}
+
+// TableToJSON encodes an in-memory result like QueryToJSON
+func TableToJSON(t *resultTable) ([]byte, error) {
+	var data bytes.Buffer
+	data.WriteByte('[')
+	for r, vals := range t.Rows {
+		if r > 0 {
+			data.WriteByte(',')
+		}
+		data.WriteByte('{')
+		for i, v := range vals {
+			if i > 0 {
+				data.WriteByte(',')
+			}
+			k, err := json.Marshal(t.Columns[i])
+			if err != nil {
+				return nil, err
+			}
+			b, err := json.Marshal(jsonValue(v, t.OIDs[i]))
+			if err != nil {
+				return nil, fmt.Errorf("encode column %s: %w", t.Columns[i], err)
+			}
+			data.Write(k)
+			data.WriteByte(':')
+			data.Write(b)
+		}
+		data.WriteByte('}')
+	}
+	data.WriteByte(']')
+
+	return json.Marshal(jsonResult{
+		Columns:     t.Columns,
+		Data:        data.Bytes(),
+		RowCount:    len(t.Rows),
+		GeneratedAt: time.Now(),
+	})
+}
+
+// jsonValue converts a pgx value into something encoding/json renders with
+// the right JSON type.
+func jsonValue(v any, oid uint32) any {
+	switch x := v.(type) {
+	case nil:
+		return nil
+	case time.Time:
+		if oid == pgtype.DateOID {
+			return x.Format("2006-01-02")
+		}
+		return x.Format(time.RFC3339)
+	case pgtype.Numeric:
+		f, err := x.Float64Value()
+		if err != nil || !f.Valid || math.IsNaN(f.Float64) || math.IsInf(f.Float64, 0) {
+			return nil
+		}
+		return f.Float64
+	case float64:
+		if math.IsNaN(x) || math.IsInf(x, 0) {
+			return nil
+		}
+		return x
+	case float32:
+		return jsonValue(float64(x), oid)
+	case [16]byte:
+		return formatUUID(x)
+	default:
+		return x
+	}
+}
+
+// formatUUID renders a UUID in canonical 8-4-4-4-12 form
+func formatUUID(u [16]byte) string {
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:16])
+}
@@ -0,0 +1,240 @@
+package httpx
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"serumpun-data-api/internal/db"
+	"serumpun-data-api/internal/plane"
+)
+
+// Notify modes
+const (
+	NotifyInvalidate = "invalidate" // expire entries, rebuild on next request
+	NotifyPrewarm    = "prewarm"    // expire entries and rebuild them right away
+)
+
+// maxPrewarmPerFlush caps how many entries one change queues for rebuilding;
+// the others are only expired and rebuilt on their next request
+const maxPrewarmPerFlush = 20
+
+// prewarmQueueSize bounds the rebuilds waiting for the prewarm worker
+const prewarmQueueSize = 100
+
+// prewarmJob is one cache entry waiting to be rebuilt
+type prewarmJob struct {
+	key     string
+	refresh func() (any, error)
+}
+
+// changeEvent is the payload sent by the notify triggers
+type changeEvent struct {
+	Table     string `json:"table"`
+	Op        string `json:"op"`
+	ProjectID string `json:"project_id"`
+}
+
+// NotifyStatus is shown by GET /api/v1/admin/notify
+type NotifyStatus struct {
+	Mode              string    `json:"mode"`
+	Channel           string    `json:"channel"`
+	TriggersInstalled int       `json:"triggers_installed"`
+	TriggerTables     int       `json:"trigger_tables"`
+	Listening         bool      `json:"listening"`
+	Connected         bool      `json:"connected"`
+	LastError         string    `json:"last_error,omitempty"`
+	Events            uint64    `json:"events"`
+	LastEventAt       time.Time `json:"last_event_at"`
+	Invalidated       uint64    `json:"invalidated"`
+}
+
+// ChangeNotifier turns Plane change notifications into cache invalidation
+// (or pre-warming) for the affected project. Bursts of notifications for a
+// project are debounced into one pass. Without it, or when the triggers are
+// not installed, the cache simply lives by its TTL.
+type ChangeNotifier struct {
+	s        *Server
+	channel  string
+	debounce time.Duration
+	prewarm  bool
+
+	mu      sync.Mutex
+	pending map[string]bool                // project key -> pass scheduled
+	warmers map[string]func() (any, error) // cached key -> rebuild (prewarm mode)
+	queue   chan prewarmJob                // rebuilds for the prewarm worker
+	status  NotifyStatus
+}
+
+// NewChangeNotifier creates a notifier for s; mode is NotifyInvalidate or
+// NotifyPrewarm. In prewarm mode it follows removals from s.Cache, so only
+// entries still cached keep a rebuild function.
+func NewChangeNotifier(s *Server, channel, mode string, debounce time.Duration) *ChangeNotifier {
+	n := &ChangeNotifier{
+		s:        s,
+		channel:  channel,
+		debounce: debounce,
+		prewarm:  mode == NotifyPrewarm,
+		pending:  map[string]bool{},
+		warmers:  map[string]func() (any, error){},
+		queue:    make(chan prewarmJob, prewarmQueueSize),
+		status:   NotifyStatus{Mode: mode, Channel: channel, TriggerTables: len(db.NotifyTables)},
+	}
+	if n.prewarm {
+		s.Cache.OnRemove(n.forget)
+	}
+	return n
+}
+
+// Start checks that the triggers are installed and starts listening on a
+// dedicated connection. Without triggers it logs and stays TTL-only.
+func (n *ChangeNotifier) Start(ctx context.Context, databaseURL string) {
+	installed, err := db.TriggersInstalled(ctx, n.s.DB)
+	n.mu.Lock()
+	n.status.TriggersInstalled = installed
+	n.mu.Unlock()
+	switch {
+	case err != nil:
+		log.Printf("notify: cannot check triggers (%v), cache is TTL-only", err)
+		return
+	case installed == 0:
+		log.Printf("notify: triggers not installed (run with -install-triggers), cache is TTL-only")
+		return
+	case installed < len(db.NotifyTables):
+		log.Printf("notify: triggers installed on %d of %d tables, some changes only show up after the TTL", installed, len(db.NotifyTables))
+	}
+
+	n.mu.Lock()
+	n.status.Listening = true
+	n.mu.Unlock()
+	log.Printf("notify: listening on %q (%s)", n.channel, n.status.Mode)
+	if n.prewarm {
+		go n.warm(ctx)
+	}
+	go db.Listen(ctx, databaseURL, n.channel, n.Handle, n.setState)
+}
+
+func (n *ChangeNotifier) setState(connected bool, err error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.status.Connected = connected
+	if err != nil {
+		n.status.LastError = err.Error()
+	}
+}
+
+// Status returns the listener status
+func (n *ChangeNotifier) Status() NotifyStatus {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.status
+}
+
+// Handle processes one notification payload
+func (n *ChangeNotifier) Handle(payload string) {
+	var ev changeEvent
+	if err := json.Unmarshal([]byte(payload), &ev); err != nil {
+		log.Printf("notify: bad payload %q: %v", payload, err)
+		return
+	}
+	project, ok := n.s.Projects.ByProjectID(ev.ProjectID)
+	if !ok {
+		return // not a project we report on
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.status.Events++
+	n.status.LastEventAt = time.Now()
+	if n.pending[project.Key] {
+		return
+	}
+	n.pending[project.Key] = true
+	time.AfterFunc(n.debounce, func() { n.flush(project) })
+}
+
+// flush expires the project's report entries and, in prewarm mode, queues
+// up to maxPrewarmPerFlush of the ones that have a registered refresher for
+// rebuilding. Entries that do not fit stay expired.
+func (n *ChangeNotifier) flush(project plane.Project) {
+	n.mu.Lock()
+	delete(n.pending, project.Key)
+	n.mu.Unlock()
+
+	expired := 0
+	for _, prefix := range reportCachePrefixes {
+		expired += n.s.Cache.Expire(projectCachePrefix(prefix, project))
+	}
+	n.s.Cache.Expire(projectCachePrefix(lastModifiedCacheName, project))
+
+	warmed := 0
+	if n.prewarm {
+		n.mu.Lock()
+	warmers:
+		for key, refresh := range n.warmers {
+			for _, prefix := range reportCachePrefixes {
+				if !strings.HasPrefix(key, projectCachePrefix(prefix, project)) {
+					continue
+				}
+				if warmed == maxPrewarmPerFlush {
+					break warmers
+				}
+				select {
+				case n.queue <- prewarmJob{key: key, refresh: refresh}:
+					warmed++
+				default:
+					break warmers // worker is behind; the rest stays expired
+				}
+				break
+			}
+		}
+		n.mu.Unlock()
+	}
+
+	n.mu.Lock()
+	n.status.Invalidated += uint64(expired)
+	n.mu.Unlock()
+	log.Printf("notify: project %s changed, expired %d cache entries, pre-warming %d", project.Key, expired, warmed)
+}
+
+// warm rebuilds queued entries one at a time until ctx is done, so a change
+// never sends more than one prewarm query to the database at once
+func (n *ChangeNotifier) warm(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case job := <-n.queue:
+			if _, err, _ := n.s.inflight.Do(job.key, job.refresh); err != nil {
+				log.Printf("%s: pre-warm failed: %v", job.key, err)
+			}
+		}
+	}
+}
+
+// remember registers how to rebuild a cache entry, for prewarm mode; it is
+// called when the entry is stored (see storeReport). It is safe to call on a
+// nil notifier.
+func (n *ChangeNotifier) remember(cacheKey string, refresh func() (any, error)) {
+	if n == nil || !n.prewarm {
+		return
+	}
+	n.mu.Lock()
+	n.warmers[cacheKey] = refresh
+	n.mu.Unlock()
+}
+
+// forget drops the rebuild function of an entry that left the cache. It is
+// the cache's OnRemove hook, so it must not call back into the cache. It is
+// safe to call on a nil notifier.
+func (n *ChangeNotifier) forget(cacheKey string) {
+	if n == nil || !n.prewarm {
+		return
+	}
+	n.mu.Lock()
+	delete(n.warmers, cacheKey)
+	n.mu.Unlock()
+}
@@ -58,3 +58,34 @@ func (s *Server) AggIssuesByUserBidang(ctx context.Context, bidangList []string,
 	}
 	return out, rows.Err()
 }
+
+// ProjectMember is a Plane project membership with the account email as stored
+type ProjectMember struct {
+	UserID      string
+	Email       string
+	DisplayName string
+	Active      bool
+}
+
+func (s *Server) ProjectMembers(ctx context.Context, workspaceID, projectID string) ([]ProjectMember, error) {
+	sqlText, err := s.Queries.Load("project_members.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.DB.Query(ctx, sqlText, workspaceID, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []ProjectMember{}
+	for rows.Next() {
+		var m ProjectMember
+		if err := rows.Scan(&m.UserID, &m.Email, &m.DisplayName, &m.Active); err != nil {
+			return nil, err
+		}
+		out = append(out, m)
+	}
+	return out, rows.Err()
+}
@@ -1,184 +1,320 @@
 package httpx
 
 import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
+
+	"serumpun-data-api/internal/plane"
 )
 
-// buildDynamicSQL loads SQL template and replaces placeholders with dynamic content
-func buildDynamicSQL(template string, replacements map[string]string) string {
-	sql := template
-	for placeholder, value := range replacements {
-		sql = strings.ReplaceAll(sql, placeholder, value)
-	}
-	return sql
+// sqlQuery is a rendered SQL template together with its positional arguments.
+// Every value that comes from a request or from the directory CSV travels in
+// Args; only whitelisted column names and $n placeholders end up in SQL.
+type sqlQuery struct {
+	SQL  string
+	Args []any
 }
 
-// buildNamaCases generates CASE statement for nama mapping from directory
-func buildNamaCases(rows []DirectoryRow) string {
-	var cases []string
-	for _, row := range rows {
-		email := strings.ToLower(row.Email)
-		nama := sanitizeSQL(row.Nama)
-		if email != "" && nama != "" {
-			cases = append(cases, "      WHEN LOWER(u.email) = '"+email+"' THEN '"+nama+"'")
-		}
-	}
-	if len(cases) == 0 {
-		// Return a dummy case that will never match
-		return "      WHEN 1=0 THEN ''"
-	}
-	return strings.Join(cases, "\n")
+// queryBuilder collects pgx positional arguments while a template is rendered.
+// Every template reserves $1 for the workspace id and $2 for the project id.
+type queryBuilder struct {
+	args []any
 }
 
-// buildScopeCases generates CASE statement for scope mapping from directory
-func buildScopeCases(rows []DirectoryRow) string {
-	var cases []string
-	for _, row := range rows {
-		email := strings.ToLower(row.Email)
-		if email != "" && row.Scope != "" {
-			cases = append(cases, "      WHEN LOWER(u.email) = '"+email+"' THEN '"+row.Scope+"'")
-		}
-	}
-	if len(cases) == 0 {
-		return "      WHEN 1=0 THEN ''"
-	}
-	return strings.Join(cases, "\n")
+// newQueryBuilder returns a builder with the project arguments bound as $1/$2
+func newQueryBuilder(project plane.Project) *queryBuilder {
+	return &queryBuilder{args: []any{project.WorkspaceID, project.ProjectID}}
 }
 
-// buildInstansiCases generates CASE statement for instansi mapping from directory
-func buildInstansiCases(rows []DirectoryRow) string {
-	var cases []string
-	for _, row := range rows {
-		email := strings.ToLower(row.Email)
-		if email != "" && row.Instansi != "" {
-			cases = append(cases, "      WHEN LOWER(u.email) = '"+email+"' THEN '"+row.Instansi+"'")
-		}
-	}
-	if len(cases) == 0 {
-		return "      WHEN 1=0 THEN ''"
-	}
-	return strings.Join(cases, "\n")
+// arg appends v to the argument list and returns its placeholder ($1, $2, ...)
+func (b *queryBuilder) arg(v any) string {
+	b.args = append(b.args, v)
+	return "$" + strconv.Itoa(len(b.args))
 }
 
-// buildBidangCases generates CASE statement for bidang mapping from directory
-func buildBidangCases(rows []DirectoryRow) string {
-	var cases []string
-	for _, row := range rows {
-		email := strings.ToLower(row.Email)
-		if email != "" && row.Bidang != "" {
-			cases = append(cases, "      WHEN LOWER(u.email) = '"+email+"' THEN '"+row.Bidang+"'")
-		}
-	}
-	if len(cases) == 0 {
-		return "      WHEN 1=0 THEN ''"
+// textArg appends a text value and returns a typed placeholder ($n::text)
+func (b *queryBuilder) textArg(v string) string {
+	return b.arg(v) + "::text"
+}
+
+// dateArg appends a YYYY-MM-DD value and returns a typed placeholder
+// ($n::date); an empty value renders as NULL::date
+func (b *queryBuilder) dateArg(v string) string {
+	if v == "" {
+		return "NULL::date"
 	}
-	return strings.Join(cases, "\n")
+	return b.arg(v) + "::date"
 }
 
-// buildJabatanCases generates CASE statement for jabatan mapping from directory
-func buildJabatanCases(rows []DirectoryRow) string {
-	var cases []string
-	for _, row := range rows {
-		email := strings.ToLower(row.Email)
-		if email != "" && row.Jabatan != "" {
-			cases = append(cases, "      WHEN LOWER(u.email) = '"+email+"' THEN '"+row.Jabatan+"'")
-		}
+// build replaces template placeholders and returns the final query
+func (b *queryBuilder) build(template string, replacements map[string]string) sqlQuery {
+	return sqlQuery{SQL: buildDynamicSQL(template, replacements), Args: b.args}
+}
+
+// filterColumns maps a query parameter name to the SQL column it filters.
+// It is the per-endpoint whitelist: parameters not listed here are ignored
+// and never reach the SQL text.
+type filterColumns map[string]string
+
+// Filter whitelists per endpoint
+var (
+	kpiProvinsiFilters = filterColumns{
+		"bidang":  "d.bidang",
+		"jabatan": "d.jabatan",
+	}
+	kpiKabkotFilters = filterColumns{
+		"bidang":   "d.bidang",
+		"instansi": "d.instansi",
+		"jabatan":  "d.jabatan",
+	}
+	heatmapFilters = filterColumns{
+		"kab_kota": "kab_kota",
+		"bidang":   "bidang",
+	}
+	issuesDetailFilters = filterColumns{
+		"scope":    "scope",
+		"kab_kota": "kab_kota",
+		"bidang":   "bidang",
+		"status":   "status",
+	}
+	timelineFilters = filterColumns{
+		"scope":    "scope",
+		"kab_kota": "kab_kota",
+		"bidang":   "bidang",
+		"status":   "status",
+	}
+	leaderboardFilters = filterColumns{
+		"scope":  "scope",
+		"bidang": "bidang",
+	}
+	workloadFilters = filterColumns{
+		"scope":  "scope",
+		"bidang": "bidang",
+	}
+	burnupFilters = filterColumns{
+		"scope":    "scope",
+		"bidang":   "bidang",
+		"kab_kota": "kab_kota",
+	}
+	cfdFilters = filterColumns{
+		"scope":    "scope",
+		"bidang":   "bidang",
+		"kab_kota": "kab_kota",
+	}
+	cycleTimeFilters = filterColumns{
+		"scope":    "scope",
+		"bidang":   "bidang",
+		"kab_kota": "kab_kota",
 	}
-	if len(cases) == 0 {
-		return "      WHEN 1=0 THEN ''"
+	forecastFilters = filterColumns{
+		"scope":    "scope",
+		"bidang":   "bidang",
+		"kab_kota": "kab_kota",
 	}
-	return strings.Join(cases, "\n")
+	dataQualityFilters = filterColumns{
+		"reason": "reason",
+		"status": "status",
+	}
+	reconciliationFilters = filterColumns{
+		"reason": "reason",
+	}
+	onboardingFilters = filterColumns{
+		"instansi": "instansi",
+		"bidang":   "bidang",
+		"status":   "status",
+	}
+)
+
+// columnPattern is what a whitelisted column may look like: an optional table
+// alias followed by a plain lower-case identifier.
+var columnPattern = regexp.MustCompile(`^([a-z_][a-z0-9_]*\.)?[a-z_][a-z0-9_]*$`)
+
+// parseFilters reads the whitelisted parameters from the request query string.
+// Values are trimmed, so the SQL arguments and the cache key see the same value.
+func parseFilters(r *http.Request, allowed filterColumns) map[string]string {
+	q := r.URL.Query()
+	filters := make(map[string]string, len(allowed))
+	for param := range allowed {
+		filters[param] = strings.TrimSpace(q.Get(param))
+	}
+	return filters
 }
 
-// buildEmailList generates comma-separated quoted email list
-func buildEmailList(rows []DirectoryRow) string {
-	var emails []string
-	for _, row := range rows {
-		email := strings.ToLower(row.Email)
-		if email != "" {
-			emails = append(emails, "'"+email+"'")
+// parseDateRange reads the optional ?from= and ?to= dates (YYYY-MM-DD) and
+// returns them as options. An invalid date or from after to is a 400.
+func parseDateRange(r *http.Request) (map[string]string, error) {
+	q := r.URL.Query()
+	opts := map[string]string{}
+	var dates [2]time.Time
+	for i, name := range []string{"from", "to"} {
+		v := strings.TrimSpace(q.Get(name))
+		if v == "" {
+			continue
 		}
+		t, err := time.Parse(time.DateOnly, v)
+		if err != nil {
+			return nil, errorStatus(http.StatusBadRequest, "invalid %s date %q (expected YYYY-MM-DD)", name, v)
+		}
+		dates[i] = t
+		opts[name] = t.Format(time.DateOnly)
 	}
-	if len(emails) == 0 {
-		// Return a dummy email that will never match
-		return "'__no_email__'"
+	if !dates[0].IsZero() && !dates[1].IsZero() && dates[0].After(dates[1]) {
+		return nil, errorStatus(http.StatusBadRequest, "from (%s) is after to (%s)", opts["from"], opts["to"])
 	}
-	return strings.Join(emails, ", ")
+	return opts, nil
 }
 
-// buildWhereClause generates WHERE clause from filter map
-func buildWhereClause(filters map[string]string) string {
-	if len(filters) == 0 {
-		return ""
+// buildFilterConditions turns filters into "column = $n" conditions.
+// Parameters are processed in sorted order so the same filters always render
+// the same SQL text.
+func buildFilterConditions(b *queryBuilder, allowed filterColumns, filters map[string]string) ([]string, error) {
+	params := make([]string, 0, len(filters))
+	for param := range filters {
+		params = append(params, param)
 	}
+	sort.Strings(params)
 
 	var clauses []string
-	for column, value := range filters {
-		if value != "" {
-			clauses = append(clauses, "  "+column+" = '"+sanitizeSQL(value)+"'")
+	for _, param := range params {
+		value := filters[param]
+		if value == "" {
+			continue
 		}
+		column, ok := allowed[param]
+		if !ok {
+			return nil, fmt.Errorf("filter %q is not allowed for this endpoint", param)
+		}
+		if !columnPattern.MatchString(column) {
+			return nil, fmt.Errorf("invalid filter column %q", column)
+		}
+		clauses = append(clauses, "  "+column+" = "+b.textArg(value))
 	}
+	return clauses, nil
+}
 
-	if len(clauses) == 0 {
-		return ""
+// buildDynamicSQL loads SQL template and replaces placeholders with dynamic content
+func buildDynamicSQL(template string, replacements map[string]string) string {
+	sql := template
+	for placeholder, value := range replacements {
+		sql = strings.ReplaceAll(sql, placeholder, value)
 	}
-
-	return "\nWHERE\n" + strings.Join(clauses, "\n  AND ")
+	return sql
 }
 
-// buildAdditionalWhere generates additional WHERE conditions (for appending to existing WHERE)
-func buildAdditionalWhere(filters map[string]string) string {
-	if len(filters) == 0 {
-		return ""
-	}
+// buildDirectoryArrays binds the directory as seven parallel text[] arguments
+// (email, nama, instansi, scope, jabatan, bidang, kab_kota) for the templates'
+// unnest({{DIRECTORY}}) relation. kab_kota is resolved from the instansi via
+// the region registry. Emails are lower-cased and only the first row per email
+// is kept, so joining on email never multiplies rows.
+func buildDirectoryArrays(b *queryBuilder, rows []DirectoryRow, regions *RegionRegistry) string {
+	var emails, nama, instansi, scope, jabatan, bidang, kabKota []string
+	seen := make(map[string]struct{}, len(rows))
+	for _, row := range rows {
+		email := strings.ToLower(row.Email)
+		if email == "" {
+			continue
+		}
+		if _, dup := seen[email]; dup {
+			continue
+		}
+		seen[email] = struct{}{}
 
-	var clauses []string
-	for column, value := range filters {
-		if value != "" {
-			clauses = append(clauses, "  d."+column+" = '"+sanitizeSQL(value)+"'")
+		kab := ""
+		if rg, ok := regions.ByInstansi(row.Instansi); ok {
+			kab = rg.Nama
 		}
+
+		emails = append(emails, email)
+		nama = append(nama, row.Nama)
+		instansi = append(instansi, row.Instansi)
+		scope = append(scope, row.Scope)
+		jabatan = append(jabatan, row.Jabatan)
+		bidang = append(bidang, row.Bidang)
+		kabKota = append(kabKota, kab)
 	}
 
-	if len(clauses) == 0 {
-		return ""
+	return bindTextArrays(b, emails, nama, instansi, scope, jabatan, bidang, kabKota)
+}
+
+// buildMemberArrays binds every directory member as parallel arguments
+// (email, nama, instansi, bidang, jabatan as text[]; invited, joined as
+// boolean[]) for the onboarding template's unnest({{MEMBERS}}) relation.
+// Only the first row per email is kept.
+func buildMemberArrays(b *queryBuilder, members []DirectoryMember) string {
+	var emails, nama, instansi, bidang, jabatan []string
+	invited, joined := []bool{}, []bool{}
+	seen := make(map[string]struct{}, len(members))
+	for _, m := range members {
+		if _, dup := seen[m.Email]; dup {
+			continue
+		}
+		seen[m.Email] = struct{}{}
+
+		emails = append(emails, m.Email)
+		nama = append(nama, m.Nama)
+		instansi = append(instansi, m.Instansi)
+		bidang = append(bidang, m.Bidang)
+		jabatan = append(jabatan, m.Jabatan)
+		invited = append(invited, m.Invited)
+		joined = append(joined, m.Joined)
 	}
 
-	return "\n  AND " + strings.Join(clauses, "\n  AND ")
+	return bindTextArrays(b, emails, nama, instansi, bidang, jabatan) + ", " +
+		b.arg(invited) + "::boolean[], " + b.arg(joined) + "::boolean[]"
 }
 
-// buildHavingClause generates HAVING clause from filter map
-func buildHavingClause(filters map[string]string) string {
-	if len(filters) == 0 {
-		return ""
+// buildRegionArrays binds the kab/kota registry as (kode, nama) text[]
+// arguments for the templates' unnest({{WILAYAH}}) relation.
+func buildRegionArrays(b *queryBuilder, regions *RegionRegistry) string {
+	var kode, nama []string
+	for _, rg := range regions.Regions {
+		kode = append(kode, rg.Kode)
+		nama = append(nama, rg.Nama)
 	}
+	return bindTextArrays(b, kode, nama)
+}
 
-	var clauses []string
-	for column, value := range filters {
-		if value != "" {
-			clauses = append(clauses, "  "+column+" = '"+sanitizeSQL(value)+"'")
+// bindTextArrays binds each column as a text[] argument and returns the
+// comma-separated placeholders
+func bindTextArrays(b *queryBuilder, cols ...[]string) string {
+	placeholders := make([]string, len(cols))
+	for i, col := range cols {
+		if col == nil {
+			col = []string{}
 		}
+		placeholders[i] = b.arg(col) + "::text[]"
 	}
+	return strings.Join(placeholders, ", ")
+}
 
-	if len(clauses) == 0 {
-		return ""
+// buildWhereClause generates WHERE clause from filter map
+func buildWhereClause(b *queryBuilder, allowed filterColumns, filters map[string]string) (string, error) {
+	clauses, err := buildFilterConditions(b, allowed, filters)
+	if err != nil || len(clauses) == 0 {
+		return "", err
 	}
-
-	return "\nHAVING\n" + strings.Join(clauses, "\n  AND ")
+	return "\nWHERE\n" + strings.Join(clauses, "\n  AND "), nil
 }
 
-// buildCacheKey generates cache key from base and filters
-func buildCacheKey(base string, filters map[string]string) string {
-	if len(filters) == 0 {
-		return base
+// buildAdditionalWhere generates additional WHERE conditions (for appending to existing WHERE)
+func buildAdditionalWhere(b *queryBuilder, allowed filterColumns, filters map[string]string) (string, error) {
+	clauses, err := buildFilterConditions(b, allowed, filters)
+	if err != nil || len(clauses) == 0 {
+		return "", err
 	}
+	return "\n  AND " + strings.Join(clauses, "\n  AND "), nil
+}
 
-	key := base
-	// Sort keys for consistent cache keys
-	keys := []string{"scope", "kab_kota", "bidang", "instansi", "jabatan", "status"}
-	for _, k := range keys {
-		if v, ok := filters[k]; ok && v != "" {
-			key += "_" + v
-		}
+// buildHavingClause generates HAVING clause from filter map
+func buildHavingClause(b *queryBuilder, allowed filterColumns, filters map[string]string) (string, error) {
+	clauses, err := buildFilterConditions(b, allowed, filters)
+	if err != nil || len(clauses) == 0 {
+		return "", err
 	}
-	return key
+	return "\nHAVING\n" + strings.Join(clauses, "\n  AND "), nil
 }
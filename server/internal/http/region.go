@@ -0,0 +1,121 @@
+package httpx
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Region is one kabupaten/kota with its BPS code and the BPS office
+// (Asal Instansi in the directory CSV) that covers it.
+type Region struct {
+	Kode     string // BPS code, e.g. 2171
+	Nama     string // display name used in reports, e.g. Batam
+	Instansi string // e.g. BPS Kota Batam
+}
+
+// RegionRegistry maps BPS kab/kota codes and instansi names to regions.
+type RegionRegistry struct {
+	Regions    []Region
+	byKode     map[string]Region
+	byInstansi map[string]Region
+}
+
+var regionKodePattern = regexp.MustCompile(`^[0-9]{4}$`)
+
+// LoadRegionsFromCSV reads the kab/kota registry (columns: kode, nama, instansi)
+func LoadRegionsFromCSV(path string) (*RegionRegistry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open wilayah csv: %w", err)
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.TrimLeadingSpace = true
+
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+	col := indexHeader(header)
+	for _, k := range []string{"kode", "nama", "instansi"} {
+		if _, ok := col[k]; !ok {
+			return nil, fmt.Errorf("missing column %q in wilayah csv", k)
+		}
+	}
+
+	reg := &RegionRegistry{byKode: map[string]Region{}, byInstansi: map[string]Region{}}
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read row: %w", err)
+		}
+
+		rg := Region{
+			Kode:     strings.TrimSpace(rec[col["kode"]]),
+			Nama:     strings.TrimSpace(rec[col["nama"]]),
+			Instansi: strings.TrimSpace(rec[col["instansi"]]),
+		}
+		if !regionKodePattern.MatchString(rg.Kode) {
+			return nil, fmt.Errorf("invalid kode %q in wilayah csv", rg.Kode)
+		}
+		if rg.Nama == "" {
+			return nil, fmt.Errorf("kode %s has no nama in wilayah csv", rg.Kode)
+		}
+		if _, dup := reg.byKode[rg.Kode]; dup {
+			return nil, fmt.Errorf("duplicate kode %s in wilayah csv", rg.Kode)
+		}
+
+		reg.Regions = append(reg.Regions, rg)
+		reg.byKode[rg.Kode] = rg
+		if rg.Instansi != "" {
+			reg.byInstansi[normInstansi(rg.Instansi)] = rg
+		}
+	}
+
+	if len(reg.Regions) == 0 {
+		return nil, fmt.Errorf("wilayah csv has no regions")
+	}
+	return reg, nil
+}
+
+// ByKode returns the region for a BPS code
+func (g *RegionRegistry) ByKode(kode string) (Region, bool) {
+	rg, ok := g.byKode[kode]
+	return rg, ok
+}
+
+// ByInstansi returns the region covered by a BPS office name
+func (g *RegionRegistry) ByInstansi(instansi string) (Region, bool) {
+	rg, ok := g.byInstansi[normInstansi(instansi)]
+	return rg, ok
+}
+
+// KodePattern returns the POSIX regex used to pull a kab/kota code out of a
+// Plane display name, built from the province prefixes in the registry,
+// e.g. ((21)[0-9]{2}).
+func (g *RegionRegistry) KodePattern() string {
+	set := map[string]struct{}{}
+	for _, rg := range g.Regions {
+		set[rg.Kode[:2]] = struct{}{}
+	}
+	prefixes := make([]string, 0, len(set))
+	for p := range set {
+		prefixes = append(prefixes, p)
+	}
+	sort.Strings(prefixes)
+	return "((" + strings.Join(prefixes, "|") + ")[0-9]{2})"
+}
+
+// normInstansi lower-cases and collapses whitespace for instansi matching
+func normInstansi(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(s), " "))
+}
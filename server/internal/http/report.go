@@ -0,0 +1,531 @@
+package httpx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"serumpun-data-api/internal/cache"
+	"serumpun-data-api/internal/plane"
+)
+
+// defaultStreamCacheMaxBytes is the largest streamed CSV that is still cached
+const defaultStreamCacheMaxBytes = 8 << 20
+
+// Report output formats
+const (
+	formatCSV  = "csv"
+	formatJSON = "json"
+	formatXLSX = "xlsx"
+)
+
+// xlsxContentType is the media type of .xlsx workbooks
+const xlsxContentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+// reportSpec describes one request to a report endpoint
+type reportSpec struct {
+	Name      string // endpoint name, also the cache key prefix
+	Project   plane.Project
+	Filters   map[string]string
+	Options   map[string]string // validated non-filter parameters (date range, mode, ...)
+	Templates []string          // SQL templates Build renders, part of the cache key
+
+	// Build renders the SQL template; it only runs on a cache miss
+	Build func() (sqlQuery, error)
+
+	// Compute, when set, replaces Build for reports computed in Go from
+	// their own queries. Its result is rendered in the requested format and
+	// never streamed.
+	Compute func(ctx context.Context) (*resultTable, error)
+}
+
+// httpError is an error that should be reported with a specific status
+type httpError struct {
+	Status int
+	Msg    string
+}
+
+func (e *httpError) Error() string { return e.Msg }
+
+// errorStatus builds an httpError
+func errorStatus(status int, format string, a ...any) error {
+	return &httpError{Status: status, Msg: fmt.Sprintf(format, a...)}
+}
+
+// writeError writes err with its httpError status, or 500
+func writeError(w http.ResponseWriter, err error) {
+	var he *httpError
+	if errors.As(err, &he) {
+		http.Error(w, he.Msg, he.Status)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+// serveReport is the shared cache -> query -> write path of every report
+// endpoint. The format is negotiated per request and cached separately.
+// CSV misses are streamed to the client as pgx returns rows. Caching,
+// coalescing and stale handling are done by serveCached.
+func (s *Server) serveReport(w http.ResponseWriter, r *http.Request, spec reportSpec) {
+	format, err := requestFormat(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	cf := s.CSV
+	if v := r.URL.Query().Get("locale"); v != "" {
+		locale, err := ParseLocale(v)
+		if err != nil {
+			writeError(w, errorStatus(http.StatusBadRequest, "%s", err.Error()))
+			return
+		}
+		cf = cf.WithLocale(locale)
+	}
+
+	// Build cache key
+	params := map[string]string{"format": format}
+	if format == formatCSV {
+		params["locale"] = cf.Locale
+	}
+	for k, v := range spec.Filters {
+		params["filter:"+k] = v
+	}
+	for k, v := range spec.Options {
+		params["option:"+k] = v
+	}
+	cacheKey, err := s.reportCacheKey(cacheKeyInput{
+		Endpoint:  spec.Name,
+		Project:   spec.Project,
+		Templates: spec.Templates,
+		Params:    params,
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// refresh rebuilds the payload without a client attached; it is used for
+	// background revalidation and for buffered formats
+	var refresh func() (any, error)
+	refresh = func() (any, error) {
+		// Execute query
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), 25*time.Second)
+		defer cancel()
+
+		modified := s.reportModified(ctx, spec.Project)
+		var b []byte
+		if spec.Compute != nil {
+			t, err := spec.Compute(ctx)
+			if err != nil {
+				return nil, err
+			}
+			if b, err = renderTable(spec.Name, format, cf, t); err != nil {
+				return nil, errorStatus(http.StatusInternalServerError, "failed to render report: %v", err)
+			}
+		} else {
+			q, err := spec.Build()
+			if err != nil {
+				return nil, err
+			}
+			switch format {
+			case formatXLSX:
+				b, err = s.queryToXLSX(ctx, spec.Name, cf, q)
+			case formatJSON:
+				b, err = QueryToJSON(ctx, s.DB, q.SQL, q.Args...)
+			default:
+				b, err = QueryToCSV(ctx, s.DB, cf, q.SQL, q.Args...)
+			}
+			if err != nil {
+				return nil, errorStatus(http.StatusInternalServerError, "query failed: %v", err)
+			}
+		}
+
+		// Set cache
+		p := reportPayload{Data: b, Modified: modified}
+		if format != formatCSV || len(b) <= s.streamCacheLimit() {
+			s.storeReport(cacheKey, p, refresh)
+		}
+		return p, nil
+	}
+
+	// CSV misses are written to the client while the rows are read
+	var stream func(w http.ResponseWriter) (reportPayload, bool, error)
+	if format == formatCSV && spec.Compute == nil {
+		stream = func(w http.ResponseWriter) (reportPayload, bool, error) {
+			q, err := spec.Build()
+			if err != nil {
+				return reportPayload{}, false, err
+			}
+			ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), 25*time.Second)
+			defer cancel()
+			modified := s.reportModified(ctx, spec.Project)
+			if notModifiedSince(r, modified) {
+				w.Header().Set("Cache-Control", s.cacheControl(spec.Name))
+				w.Header().Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+				w.WriteHeader(http.StatusNotModified)
+				return reportPayload{}, true, errNotModified
+			}
+			p, started, err := s.streamCSVReport(ctx, w, spec, cacheKey, cf, q, modified)
+			if err == nil {
+				s.storeReport(cacheKey, p, refresh)
+			}
+			return p, started, err
+		}
+	}
+
+	s.serveCached(w, r, cachedReport{
+		Spec:    spec,
+		Format:  format,
+		Key:     cacheKey,
+		Refresh: refresh,
+		Stream:  stream,
+	})
+}
+
+// cachedReport is a report request resolved to its cache key, with the ways
+// to build the payload on a miss
+type cachedReport struct {
+	Spec   reportSpec
+	Format string
+	Key    string
+
+	// Refresh builds and caches the payload without a client attached; it
+	// is shared by coalesced misses and background revalidation
+	Refresh func() (any, error)
+
+	// Stream, when set, replaces Refresh on a foreground miss: it writes
+	// the payload to w as it is read and caches it if it fits. The bool
+	// reports whether w got a response (see streamCSVReport).
+	Stream func(w http.ResponseWriter) (reportPayload, bool, error)
+}
+
+// serveCached serves a report from the cache, revalidating stale entries in
+// the background, and builds it on a miss. Concurrent misses share one build.
+// A streamed miss is written straight to the leader's client; the others
+// write the shared payload to their own client afterwards, or stream their
+// own copy when it was too large to keep. With a stale-if-error copy at hand
+// the payload is not streamed, so a failure can still fall back to it.
+func (s *Server) serveCached(w http.ResponseWriter, r *http.Request, c cachedReport) {
+	// Check cache
+	item, state := s.Cache.Lookup(c.Key)
+	cached := reportPayload{Data: item.Data, Modified: item.Modified}
+	switch state {
+	case cache.Fresh:
+		w.Header().Set("X-Cache", "HIT")
+		setAge(w, item)
+		s.writeReport(w, r, c.Spec, c.Format, cached, "hit")
+		return
+	case cache.Stale:
+		w.Header().Set("X-Cache", "STALE")
+		setAge(w, item)
+		s.revalidate(c.Key, c.Refresh)
+		s.writeReport(w, r, c.Spec, c.Format, cached, "stale")
+		return
+	}
+
+	w.Header().Set("X-Cache", "MISS")
+
+	streamable := c.Stream != nil && state != cache.StaleIfError
+	leader, started := false, false
+	v, err, shared := s.inflight.Do(c.Key, func() (any, error) {
+		leader = true
+		if !streamable {
+			return c.Refresh()
+		}
+		p, ok, err := c.Stream(w)
+		started = ok
+		if err != nil {
+			return nil, err
+		}
+		return p, nil
+	})
+
+	if shared && !leader {
+		w.Header().Set("X-Coalesced", "true")
+	}
+
+	switch {
+	case started:
+		// Our client got the stream, including any mid-stream error
+	case errors.Is(err, errStreamUncached), errors.Is(err, errNotModified):
+		// The leader's result was too large to share, or the leader's
+		// client already had it
+		if _, ok, err := c.Stream(w); !ok {
+			writeError(w, err)
+		}
+	case err == nil:
+		s.writeReport(w, r, c.Spec, c.Format, v.(reportPayload), "miss")
+	case state == cache.StaleIfError:
+		// Database failed: fall back to the last good payload
+		log.Printf("%s: serving stale result after error: %v", c.Key, err)
+		w.Header().Set("X-Cache", "STALE-IF-ERROR")
+		setAge(w, item)
+		s.writeReport(w, r, c.Spec, c.Format, cached, "stale")
+	default:
+		writeError(w, err)
+	}
+}
+
+// reportPayload is a rendered report together with the time its source data
+// last changed (for Last-Modified)
+type reportPayload struct {
+	Data     []byte
+	Modified time.Time
+}
+
+// storeReport caches a payload and, in prewarm mode, registers refresh as
+// the way to rebuild it. The registration is dropped again when the entry
+// leaves the cache.
+func (s *Server) storeReport(cacheKey string, p reportPayload, refresh func() (any, error)) {
+	s.Notifier.remember(cacheKey, refresh)
+	if !s.Cache.SetModified(cacheKey, p.Data, p.Modified) {
+		s.Notifier.forget(cacheKey)
+	}
+}
+
+// revalidate refreshes a stale cache entry in the background. It shares the
+// singleflight group with foreground misses, so each key is rebuilt once.
+func (s *Server) revalidate(cacheKey string, refresh func() (any, error)) {
+	go func() {
+		if _, err, _ := s.inflight.Do(cacheKey, refresh); err != nil {
+			log.Printf("%s: background refresh failed: %v", cacheKey, err)
+		}
+	}()
+}
+
+// setAge reports how old a cached payload is
+func setAge(w http.ResponseWriter, item cache.Item) {
+	w.Header().Set("Age", strconv.Itoa(int(item.Age().Seconds())))
+}
+
+// writeReport writes a cached or freshly built payload in its format, with
+// validators, answering conditional requests with 304
+func (s *Server) writeReport(w http.ResponseWriter, r *http.Request, spec reportSpec, format string, p reportPayload, cacheStatus string) {
+	body := p.Data
+	switch format {
+	case formatJSON:
+		var err error
+		if body, err = renderJSON(spec, p.Data, cacheStatus); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	case formatXLSX:
+		w.Header().Set("Content-Type", xlsxContentType)
+		w.Header().Set("Content-Disposition", `attachment; filename="`+spec.Name+`.xlsx"`)
+	default:
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	}
+	s.writeBody(w, r, spec.Name, body, strongETag(body), p.Modified)
+}
+
+// errNotModified is returned by a report stream that answered a conditional
+// request with 304 instead of running the query
+var errNotModified = errors.New("not modified")
+
+// notModifiedSince reports whether r's If-Modified-Since covers modified.
+// Like http.ServeContent it is ignored when If-None-Match is present.
+func notModifiedSince(r *http.Request, modified time.Time) bool {
+	if modified.IsZero() || r.Header.Get("If-None-Match") != "" || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	return err == nil && !modified.Truncate(time.Second).After(since)
+}
+
+// errStreamUncached is returned by streamCSVReport when the result was
+// larger than the cache limit, so it was not kept for coalesced requests
+var errStreamUncached = errors.New("streamed result too large to cache")
+
+// streamCSVReport runs a CSV report query straight into the client's
+// response, keeping a copy for the cache while it stays within
+// streamCacheLimit. started reports whether the client got a response;
+// false means the query failed before the first row and the caller still
+// has to write the error. Errors mid-stream are reported in the
+// X-Stream-Error trailer and a "#error" footer line. The ETag is unknown
+// until the end, so a streamed response only carries Last-Modified.
+func (s *Server) streamCSVReport(ctx context.Context, w http.ResponseWriter, spec reportSpec, cacheKey string, cf CSVFormat, q sqlQuery, modified time.Time) (p reportPayload, started bool, err error) {
+	client := newClientWriter(w)
+	buf := &cacheBuffer{limit: s.streamCacheLimit()}
+	onStart := func() {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Cache-Control", s.cacheControl(spec.Name))
+		if !modified.IsZero() {
+			w.Header().Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+		}
+		w.Header().Set("Trailer", "X-Stream-Error")
+		w.WriteHeader(http.StatusOK)
+	}
+
+	started, err = StreamCSV(ctx, s.DB, io.MultiWriter(client, buf), cf, onStart, client.flush, q.SQL, q.Args...)
+	if err != nil {
+		if !started {
+			return reportPayload{}, false, errorStatus(http.StatusInternalServerError, "query failed: %v", err)
+		}
+		msg := strings.Join(strings.Fields(err.Error()), " ")
+		log.Printf("%s: stream aborted: %s", cacheKey, msg)
+		w.Header().Set("X-Stream-Error", msg)
+		_, _ = fmt.Fprintf(client, "#error: stream aborted: %s\n", msg)
+		return reportPayload{}, true, errorStatus(http.StatusInternalServerError, "query failed mid-stream: %s", msg)
+	}
+	if buf.Overflow {
+		return reportPayload{}, true, errStreamUncached
+	}
+	return reportPayload{Data: buf.Bytes(), Modified: modified}, true, nil
+}
+
+// streamCacheLimit is the largest CSV payload that is cached
+func (s *Server) streamCacheLimit() int {
+	if s.StreamCacheMaxBytes <= 0 {
+		return defaultStreamCacheMaxBytes
+	}
+	return s.StreamCacheMaxBytes
+}
+
+// renderTable renders a computed result in a report format
+func renderTable(sheet, format string, cf CSVFormat, t *resultTable) ([]byte, error) {
+	switch format {
+	case formatXLSX:
+		return BuildXLSX([]xlsxSheet{{Name: sheet, Table: t}}, cf)
+	case formatJSON:
+		return TableToJSON(t)
+	}
+	return TableToCSV(t, cf)
+}
+
+// queryToXLSX runs a report query into a single-sheet workbook
+func (s *Server) queryToXLSX(ctx context.Context, sheet string, cf CSVFormat, q sqlQuery) ([]byte, error) {
+	t, err := QueryTable(ctx, s.DB, q.SQL, q.Args...)
+	if err != nil {
+		return nil, err
+	}
+	return BuildXLSX([]xlsxSheet{{Name: sheet, Table: t}}, cf)
+}
+
+// reportMeta is the metadata envelope of a JSON report
+type reportMeta struct {
+	Report      string            `json:"report"`
+	Project     string            `json:"project"`
+	GeneratedAt time.Time         `json:"generated_at"`
+	RowCount    int               `json:"row_count"`
+	Filters     map[string]string `json:"filters"`
+	Options     map[string]string `json:"options,omitempty"`
+	Cache       string            `json:"cache"`
+}
+
+// renderJSON wraps a cached jsonResult in the metadata envelope
+func renderJSON(spec reportSpec, b []byte, cacheStatus string) ([]byte, error) {
+	var res jsonResult
+	if err := json.Unmarshal(b, &res); err != nil {
+		return nil, fmt.Errorf("failed to decode cached report: %w", err)
+	}
+
+	applied := map[string]string{}
+	for k, v := range spec.Filters {
+		if v != "" {
+			applied[k] = v
+		}
+	}
+	options := map[string]string{}
+	for k, v := range spec.Options {
+		if v != "" {
+			options[k] = v
+		}
+	}
+
+	out, err := json.Marshal(struct {
+		Meta    reportMeta      `json:"meta"`
+		Columns []string        `json:"columns"`
+		Data    json.RawMessage `json:"data"`
+	}{
+		Meta: reportMeta{
+			Report:      spec.Name,
+			Project:     spec.Project.Key,
+			GeneratedAt: res.GeneratedAt,
+			RowCount:    res.RowCount,
+			Filters:     applied,
+			Options:     options,
+			Cache:       cacheStatus,
+		},
+		Columns: res.Columns,
+		Data:    res.Data,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode report: %w", err)
+	}
+	return out, nil
+}
+
+// requestFormat picks the output format: ?format= wins, then the .json/.xlsx
+// route alias, then the Accept header. CSV is the default.
+func requestFormat(r *http.Request) (string, error) {
+	if f := strings.ToLower(r.URL.Query().Get("format")); f != "" {
+		switch f {
+		case formatCSV, formatJSON, formatXLSX:
+			return f, nil
+		}
+		return "", errorStatus(http.StatusBadRequest, "unsupported format %q (supported: csv, json, xlsx)", f)
+	}
+
+	switch {
+	case strings.HasSuffix(r.URL.Path, ".json"):
+		return formatJSON, nil
+	case strings.HasSuffix(r.URL.Path, ".xlsx"):
+		return formatXLSX, nil
+	}
+
+	return negotiateFormat(r.Header.Get("Accept")), nil
+}
+
+// negotiateFormat chooses between CSV, JSON and XLSX from an Accept header, using
+// q-values; ties keep CSV so existing Flourish/Looker clients are unaffected.
+func negotiateFormat(accept string) string {
+	type candidate struct {
+		format string
+		q      float64
+	}
+	var cands []candidate
+	for _, part := range strings.Split(accept, ",") {
+		mt, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		switch mt {
+		case "application/json":
+			cands = append(cands, candidate{formatJSON, q})
+		case xlsxContentType:
+			cands = append(cands, candidate{formatXLSX, q})
+		case "text/csv":
+			cands = append(cands, candidate{formatCSV, q})
+		}
+	}
+	if len(cands) == 0 {
+		return formatCSV
+	}
+	sort.SliceStable(cands, func(a, b int) bool {
+		if cands[a].q != cands[b].q {
+			return cands[a].q > cands[b].q
+		}
+		return cands[a].format == formatCSV && cands[b].format != formatCSV
+	})
+	if cands[0].q <= 0 {
+		return formatCSV
+	}
+	return cands[0].format
+}
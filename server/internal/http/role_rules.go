@@ -0,0 +1,150 @@
+package httpx
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Role levels. Level ketua decides who is counted in KPI Kabkot.
+const (
+	LevelPengarah = "pengarah"
+	LevelKetua    = "ketua"
+	LevelAnggota  = "anggota"
+)
+
+// RoleRule maps a jabatan matching Pattern to a normalised jabatan, bidang
+// and level. Jabatan and Bidang may reference capture groups ($1, ${name}).
+type RoleRule struct {
+	Line    int
+	Pattern *regexp.Regexp
+	Scope   string // "" = any, provinsi | kabkot
+	Jabatan string
+	Bidang  string
+	Level   string
+}
+
+// RoleRules is the ordered role taxonomy; the first matching rule wins.
+type RoleRules struct {
+	Rules []RoleRule
+}
+
+// Role is the classification of one jabatan
+type Role struct {
+	Jabatan string
+	Bidang  string
+	Level   string
+	// BidangFromJabatan is set when the bidang was taken from the jabatan
+	// text ("Ketua Bidang X"); those make up the directory's BidangList
+	BidangFromJabatan bool
+	Rule              int // line of the matching rule in the rules file
+}
+
+// LoadRoleRules reads the role rules file (columns: pattern, scope, jabatan,
+// bidang, level; lines starting with # are comments)
+func LoadRoleRules(path string) (*RoleRules, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open jabatan rules: %w", err)
+	}
+	defer f.Close()
+
+	return parseRoleRules(f)
+}
+
+// parseRoleRules parses the role rules from any reader
+func parseRoleRules(in io.Reader) (*RoleRules, error) {
+	r := csv.NewReader(in)
+	r.TrimLeadingSpace = true
+	r.Comment = '#'
+
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read jabatan rules header: %w", err)
+	}
+	col := indexHeader(header)
+	for _, k := range []string{"pattern", "scope", "jabatan", "bidang", "level"} {
+		if _, ok := col[k]; !ok {
+			return nil, fmt.Errorf("missing column %q in jabatan rules", k)
+		}
+	}
+
+	rules := &RoleRules{}
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read jabatan rules: %w", err)
+		}
+		line, _ := r.FieldPos(0)
+
+		pattern := strings.TrimSpace(rec[col["pattern"]])
+		if pattern == "" {
+			return nil, fmt.Errorf("jabatan rules line %d: empty pattern", line)
+		}
+		re, err := regexp.Compile("(?i)" + pattern)
+		if err != nil {
+			return nil, fmt.Errorf("jabatan rules line %d: invalid pattern %q: %w", line, pattern, err)
+		}
+
+		rule := RoleRule{
+			Line:    line,
+			Pattern: re,
+			Scope:   strings.ToLower(strings.TrimSpace(rec[col["scope"]])),
+			Jabatan: strings.TrimSpace(rec[col["jabatan"]]),
+			Bidang:  strings.TrimSpace(rec[col["bidang"]]),
+			Level:   strings.ToLower(strings.TrimSpace(rec[col["level"]])),
+		}
+		switch rule.Scope {
+		case "", "provinsi", "kabkot":
+		default:
+			return nil, fmt.Errorf("jabatan rules line %d: unsupported scope %q (supported: provinsi, kabkot or empty)", line, rule.Scope)
+		}
+		switch rule.Level {
+		case LevelPengarah, LevelKetua, LevelAnggota:
+		default:
+			return nil, fmt.Errorf("jabatan rules line %d: unsupported level %q (supported: pengarah, ketua, anggota)", line, rule.Level)
+		}
+		if rule.Jabatan == "" || rule.Bidang == "" {
+			return nil, fmt.Errorf("jabatan rules line %d: jabatan and bidang are required", line)
+		}
+		rules.Rules = append(rules.Rules, rule)
+	}
+	if len(rules.Rules) == 0 {
+		return nil, fmt.Errorf("jabatan rules file has no rules")
+	}
+	return rules, nil
+}
+
+// Classify returns the role of the first rule matching jabatan in scope.
+// Rules without a scope apply everywhere; scope "" only matches those.
+// Runs of whitespace, including line breaks from multi-line CSV cells, are
+// collapsed to one space before matching.
+func (rr *RoleRules) Classify(jabatan, scope string) (Role, bool) {
+	j := strings.Join(strings.Fields(jabatan), " ")
+	for _, rule := range rr.Rules {
+		if rule.Scope != "" && rule.Scope != scope {
+			continue
+		}
+		m := rule.Pattern.FindStringSubmatchIndex(j)
+		if m == nil {
+			continue
+		}
+		expand := func(tmpl string) string {
+			return strings.TrimSpace(string(rule.Pattern.ExpandString(nil, tmpl, j, m)))
+		}
+		return Role{
+			Jabatan:           expand(rule.Jabatan),
+			Bidang:            expand(rule.Bidang),
+			Level:             rule.Level,
+			BidangFromJabatan: strings.Contains(rule.Bidang, "$"),
+			Rule:              rule.Line,
+		}, true
+	}
+	return Role{}, false
+}
@@ -0,0 +1,256 @@
+package httpx
+
+import (
+	"encoding/csv"
+	"os"
+	"strings"
+	"testing"
+)
+
+const (
+	rulesPath     = "../../data/jabatan_rules.csv"
+	directoryPath = "../../data/daftar_pengguna_serumpun.csv"
+	wilayahPath   = "../../data/wilayah.csv"
+)
+
+func loadTestRules(t *testing.T) *RoleRules {
+	t.Helper()
+	rules, err := LoadRoleRules(rulesPath)
+	if err != nil {
+		t.Fatalf("LoadRoleRules: %v", err)
+	}
+	return rules
+}
+
+func loadTestRegions(t *testing.T) *RegionRegistry {
+	t.Helper()
+	regions, err := LoadRegionsFromCSV(wilayahPath)
+	if err != nil {
+		t.Fatalf("LoadRegionsFromCSV: %v", err)
+	}
+	return regions
+}
+
+// TestClassifyDirectoryRows classifies every row of the shipped directory
+// CSV with the shipped rules file. A new jabatan in the CSV fails here until
+// it has a rule in data/jabatan_rules.csv and an entry in want.
+func TestClassifyDirectoryRows(t *testing.T) {
+	rules := loadTestRules(t)
+
+	type key struct{ scope, jabatan string }
+	want := map[key]Role{
+		{"provinsi", "Pengarah"}:                                   {Jabatan: "Pengarah", Bidang: "Umum", Level: LevelPengarah},
+		{"provinsi", "Ketua Pelaksana SE"}:                         {Jabatan: "Ketua Pelaksana", Bidang: "Umum", Level: LevelKetua},
+		{"provinsi", "Ketua Sekretariat"}:                          {Jabatan: "Ketua Sekretariat", Bidang: "Sekretariat", Level: LevelKetua},
+		{"provinsi", "Wakil Ketua Sekretariat"}:                    {Jabatan: "Wakil Ketua Sekretariat", Bidang: "Sekretariat", Level: LevelKetua},
+		{"provinsi", "Anggota Sekretariat"}:                        {Jabatan: "Anggota Sekretariat", Bidang: "Sekretariat", Level: LevelAnggota},
+		{"provinsi", "Ketua Bidang Administrasi, Humas, dan MR"}:   {Jabatan: "Ketua", Bidang: "Administrasi, Humas, dan MR", Level: LevelKetua},
+		{"provinsi", "Ketua Bidang Analisis dan Kualitas Data"}:    {Jabatan: "Ketua", Bidang: "Analisis dan Kualitas Data", Level: LevelKetua},
+		{"provinsi", "Ketua Bidang PTI dan Diseminasi"}:            {Jabatan: "Ketua", Bidang: "PTI dan Diseminasi", Level: LevelKetua},
+		{"provinsi", "Ketua Bidang Teknis Pendataan dan Manlap"}:   {Jabatan: "Ketua", Bidang: "Teknis Pendataan dan Manlap", Level: LevelKetua},
+		{"provinsi", "Anggota Bidang Administrasi, Humas, dan MR"}: {Jabatan: "Anggota", Bidang: "Administrasi, Humas, dan MR", Level: LevelAnggota},
+		{"provinsi", "Anggota Bidang Analisis dan Kualitas Data"}:  {Jabatan: "Anggota", Bidang: "Analisis dan Kualitas Data", Level: LevelAnggota},
+		{"provinsi", "Anggota Bidang PTI dan Diseminasi"}:          {Jabatan: "Anggota", Bidang: "PTI dan Diseminasi", Level: LevelAnggota},
+		{"provinsi", "Anggota Bidang Teknis Pendataan dan Manlap"}: {Jabatan: "Anggota", Bidang: "Teknis Pendataan dan Manlap", Level: LevelAnggota},
+		{"kabkot", "Kepala Kab/Kot"}:                               {Jabatan: "Kepala Kab/Kot", Bidang: "Umum", Level: LevelKetua},
+		{"kabkot", "Ketua Pelaksana SE"}:                           {Jabatan: "Ketua Pelaksana", Bidang: "Umum", Level: LevelKetua},
+		{"kabkot", "Ketua Sekretariat"}:                            {Jabatan: "Ketua Sekretariat", Bidang: "Sekretariat", Level: LevelKetua},
+		{"kabkot", "Wakil Ketua Sekretariat"}:                      {Jabatan: "Wakil Ketua Sekretariat", Bidang: "Sekretariat", Level: LevelKetua},
+		{"kabkot", "Anggota Sekretariat"}:                          {Jabatan: "Anggota Sekretariat", Bidang: "Sekretariat", Level: LevelAnggota},
+		{"kabkot", "Ketua Bidang Administrasi, Humas, dan MR"}:     {Jabatan: "Ketua Bidang", Bidang: "Administrasi, Humas, dan MR", Level: LevelKetua},
+		{"kabkot", "Ketua Bidang Analisis dan Kualitas Data"}:      {Jabatan: "Ketua Bidang", Bidang: "Analisis dan Kualitas Data", Level: LevelKetua},
+		{"kabkot", "Ketua Bidang PTI dan Diseminasi"}:              {Jabatan: "Ketua Bidang", Bidang: "PTI dan Diseminasi", Level: LevelKetua},
+		{"kabkot", "Ketua Bidang Teknis Pendataan dan Manlap"}:     {Jabatan: "Ketua Bidang", Bidang: "Teknis Pendataan dan Manlap", Level: LevelKetua},
+		{"kabkot", "Anggota Bidang Administrasi, Humas, dan MR"}:   {Jabatan: "Anggota", Bidang: "Administrasi, Humas, dan MR", Level: LevelAnggota},
+		{"kabkot", "Anggota Bidang Analisis dan Kualitas Data"}:    {Jabatan: "Anggota", Bidang: "Analisis dan Kualitas Data", Level: LevelAnggota},
+		{"kabkot", "Anggota Bidang PTI dan Diseminasi"}:            {Jabatan: "Anggota", Bidang: "PTI dan Diseminasi", Level: LevelAnggota},
+		{"kabkot", "Anggota Bidang Teknis Pendataan dan Manlap"}:   {Jabatan: "Anggota", Bidang: "Teknis Pendataan dan Manlap", Level: LevelAnggota},
+	}
+
+	f, err := os.Open(directoryPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	header, err := r.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	col := indexHeader(header)
+
+	recs, err := r.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Cells edited in a spreadsheet can wrap onto several lines; they must
+	// classify like their single-line form
+	recs = append(recs,
+		directoryRecord(col, "Multi Line", "BPS Kota Batam", "Anggota Bidang Administrasi,\nHumas, dan MR"),
+		directoryRecord(col, "Multi Line", "BPS Provinsi Kepulauan Riau", "Ketua Bidang\r\nPTI dan  Diseminasi "),
+		directoryRecord(col, "Multi Line", "BPS Kota Batam", "Wakil Ketua\nSekretariat"),
+	)
+	for i, rec := range recs {
+		jabatan := rec[col["Jabatan Dalam Tim SE2026"]]
+		scope := deriveScope(rec[col["Asal Instansi"]])
+		exp, ok := want[key{scope, strings.Join(strings.Fields(jabatan), " ")}]
+		if !ok {
+			t.Errorf("row %d (%s, %s %q): no expected classification; add a rule and a test entry", i+2, rec[col["Nama"]], scope, jabatan)
+			continue
+		}
+
+		got, ok := rules.Classify(jabatan, scope)
+		if !ok {
+			t.Errorf("row %d (%s, %s %q): no rule matches", i+2, rec[col["Nama"]], scope, jabatan)
+			continue
+		}
+		if got.Jabatan != exp.Jabatan || got.Bidang != exp.Bidang || got.Level != exp.Level {
+			t.Errorf("row %d (%s, %s %q): got %s / %s / %s (rule line %d), want %s / %s / %s",
+				i+2, rec[col["Nama"]], scope, jabatan, got.Jabatan, got.Bidang, got.Level, got.Rule,
+				exp.Jabatan, exp.Bidang, exp.Level)
+		}
+	}
+}
+
+// directoryRecord builds a directory CSV record laid out by col
+func directoryRecord(col map[string]int, nama, instansi, jabatan string) []string {
+	rec := make([]string, len(col))
+	rec[col["Nama"]] = nama
+	rec[col["Asal Instansi"]] = instansi
+	rec[col["Jabatan Dalam Tim SE2026"]] = jabatan
+	return rec
+}
+
+// TestDirectoryUsesRoleLevels checks how classified rows end up in the
+// provinsi and kabkot directory
+func TestDirectoryUsesRoleLevels(t *testing.T) {
+	rules := loadTestRules(t)
+	in := `Nama,Akun Gmail,Asal Instansi,Jabatan Dalam Tim SE2026,Invitation,Join Proyek
+A,a@example.com,BPS Provinsi Kepulauan Riau,Pengarah,Sudah,Sudah
+B,b@example.com,BPS Provinsi Kepulauan Riau,Anggota Bidang PTI dan Diseminasi,Sudah,Sudah
+C,c@example.com,BPS Kota Batam,Wakil Ketua Sekretariat,Sudah,Sudah
+D,d@example.com,BPS Kota Batam,Ketua Bidang PTI dan Diseminasi,Sudah,Belum
+E,e@example.com,BPS Kota Batam,Anggota Sekretariat,Belum,Belum
+F,f@example.com,BPS Kota Batam,Koordinator Lapangan,Belum,Belum
+`
+	res, err := parseDirectoryCSV(strings.NewReader(in), rules, loadTestRegions(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string]DirectoryRow{}
+	for _, row := range res.All() {
+		got[row.Email] = row
+	}
+	want := map[string]DirectoryRow{
+		"a@example.com": {Scope: "provinsi", Jabatan: "Pengarah", Bidang: "Umum"},
+		"b@example.com": {Scope: "provinsi", Jabatan: "Anggota", Bidang: "PTI dan Diseminasi"},
+		"c@example.com": {Scope: "kabkot", Jabatan: "Wakil Ketua Sekretariat", Bidang: "Sekretariat"},
+		"d@example.com": {Scope: "kabkot", Jabatan: "Ketua Bidang", Bidang: "PTI dan Diseminasi"},
+	}
+	if len(got) != len(want) {
+		t.Errorf("got %d directory rows, want %d: %+v", len(got), len(want), got)
+	}
+	for email, w := range want {
+		g, ok := got[email]
+		if !ok {
+			t.Errorf("%s: missing from directory", email)
+			continue
+		}
+		if g.Scope != w.Scope || g.Jabatan != w.Jabatan || g.Bidang != w.Bidang {
+			t.Errorf("%s: got %s / %s / %s, want %s / %s / %s", email, g.Scope, g.Jabatan, g.Bidang, w.Scope, w.Jabatan, w.Bidang)
+		}
+	}
+
+	if len(res.BidangList) != 1 || res.BidangList[0] != "PTI dan Diseminasi" {
+		t.Errorf("BidangList = %v, want [PTI dan Diseminasi]", res.BidangList)
+	}
+
+	var unknown []int
+	for _, is := range res.Issues {
+		if is.Code == issueUnknownJabatan {
+			unknown = append(unknown, is.Line)
+		}
+	}
+	if len(unknown) != 1 || unknown[0] != 7 {
+		t.Errorf("unknown_jabatan issues on lines %v, want [7]", unknown)
+	}
+}
+
+func TestRuleOrderFirstMatchWins(t *testing.T) {
+	in := `pattern,scope,jabatan,bidang,level
+ketua sekretariat,,Ketua Sekretariat,Sekretariat,ketua
+wakil ketua sekretariat,,Wakil Ketua Sekretariat,Sekretariat,ketua
+`
+	rules, err := parseRoleRules(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The old hardcoded order: the broader pattern shadows the Wakil rule
+	if got, _ := rules.Classify("Wakil Ketua Sekretariat", "kabkot"); got.Jabatan != "Ketua Sekretariat" {
+		t.Errorf("got %q, want the first matching rule", got.Jabatan)
+	}
+
+	// The shipped rules list the Wakil rule first
+	shipped := loadTestRules(t)
+	if got, _ := shipped.Classify("wakil ketua sekretariat", "kabkot"); got.Jabatan != "Wakil Ketua Sekretariat" {
+		t.Errorf("shipped rules: got %q, want Wakil Ketua Sekretariat", got.Jabatan)
+	}
+}
+
+func TestRuleScope(t *testing.T) {
+	rules := loadTestRules(t)
+	for _, tc := range []struct {
+		scope, want string
+	}{
+		{"provinsi", "Ketua"},
+		{"kabkot", "Ketua Bidang"},
+		{"", "Ketua"},
+	} {
+		got, ok := rules.Classify("Ketua Bidang PTI dan Diseminasi", tc.scope)
+		if !ok || got.Jabatan != tc.want {
+			t.Errorf("scope %q: got %q (%v), want %q", tc.scope, got.Jabatan, ok, tc.want)
+		}
+	}
+}
+
+func TestParseRoleRulesErrors(t *testing.T) {
+	const header = "pattern,scope,jabatan,bidang,level\n"
+	for name, in := range map[string]string{
+		"missing column":   "pattern,jabatan,bidang,level\npengarah,Pengarah,Umum,pengarah\n",
+		"invalid pattern":  header + "ketua (,,Ketua,Umum,ketua\n",
+		"empty pattern":    header + ",,Ketua,Umum,ketua\n",
+		"unknown scope":    header + "pengarah,pusat,Pengarah,Umum,pengarah\n",
+		"unknown level":    header + "pengarah,,Pengarah,Umum,direktur\n",
+		"missing jabatan":  header + "pengarah,,,Umum,pengarah\n",
+		"no rules":         header,
+		"comment only too": "# komentar\n" + header + "# tidak ada aturan\n",
+	} {
+		if _, err := parseRoleRules(strings.NewReader(in)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+// TestUnknownKabkotInstansi checks that kab/kota offices missing from the
+// region registry are reported, while known ones and the provinsi are not
+func TestUnknownKabkotInstansi(t *testing.T) {
+	in := `Nama,Akun Gmail,Asal Instansi,Jabatan Dalam Tim SE2026,Invitation,Join Proyek
+A,a@example.com,BPS Provinsi Kepulauan Riau,Pengarah,Sudah,Sudah
+B,b@example.com,BPS Kota Batam,Ketua Sekretariat,Sudah,Sudah
+C,c@example.com,BPS Kabupaten Contoh,Ketua Sekretariat,Sudah,Sudah
+`
+	res, err := parseDirectoryCSV(strings.NewReader(in), loadTestRules(t), loadTestRegions(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lines []int
+	for _, is := range res.Issues {
+		if is.Code == issueUnknownInstansi {
+			lines = append(lines, is.Line)
+		}
+	}
+	if len(lines) != 1 || lines[0] != 4 {
+		t.Errorf("unknown_instansi issues on lines %v, want [4]", lines)
+	}
+}
@@ -18,18 +18,50 @@ func NewRouter(s *Server) http.Handler {
 		r.Get("/debug/directory", s.DebugDirectory)
 		r.Get("/debug/sql", s.DebugSQL)
 
+		// Directory CSV validation (per-line issues)
+		r.Get("/directory/validation", s.DirectoryValidation)
+
+		// Admin endpoints
+		r.Route("/admin", func(r chi.Router) {
+			r.Use(s.requireAdmin)
+			r.Get("/cache", s.AdminCache)
+			r.Delete("/cache", s.AdminCachePurge)
+			r.Get("/notify", s.AdminNotify)
+		})
+
+		// Report endpoints are served as <name>.csv, <name>.json and <name>.xlsx
+		report := func(name string, h http.HandlerFunc) {
+			r.Get("/"+name+".csv", h)
+			r.Get("/"+name+".json", h)
+			r.Get("/"+name+".xlsx", h)
+		}
+
 		// Core KPI endpoints (using SQL templates)
-		r.Get("/kpi_provinsi.csv", s.KPIProvinsiTemplate)
-		r.Get("/kpi_kabkot.csv", s.KPIKabkotTemplate)
+		report("kpi_provinsi", s.KPIProvinsiTemplate)
+		report("kpi_kabkot", s.KPIKabkotTemplate)
 
 		// Supporting endpoints (using SQL templates)
-		r.Get("/heatmap.csv", s.HeatmapTemplate)
-		r.Get("/issues_detail.csv", s.IssuesDetailTemplate)
+		report("heatmap", s.HeatmapTemplate)
+		report("issues_detail", s.IssuesDetailTemplate)
 
 		// Advanced analytics endpoints (using SQL templates)
-		r.Get("/timeline.csv", s.TimelineTemplate)
-		r.Get("/leaderboard.csv", s.LeaderboardTemplate)
-		r.Get("/workload.csv", s.WorkloadTemplate)
+		report("timeline", s.TimelineTemplate)
+		report("leaderboard", s.LeaderboardTemplate)
+		report("workload", s.WorkloadTemplate)
+
+		// Flow metrics over time (using SQL templates)
+		report("burnup", s.BurnupTemplate)
+		report("cfd", s.CFDTemplate)
+		report("cycle_time", s.CycleTimeTemplate)
+		report("forecast", s.ForecastTemplate)
+
+		// Data quality audits (using SQL templates)
+		report("data_quality", s.DataQualityTemplate)
+		report("directory_reconciliation", s.DirectoryReconciliation)
+		report("onboarding", s.OnboardingTemplate)
+
+		// Workbook bundle of the leadership reports
+		r.Get("/report.xlsx", s.ReportWorkbook)
 	})
 
 	return r
@@ -0,0 +1,392 @@
+package httpx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"serumpun-data-api/internal/db"
+	"serumpun-data-api/internal/plane"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// snapshotReport describes a report that is stored daily and can be
+// compared with an earlier day (?compare=7d)
+type snapshotReport struct {
+	Name    string
+	Keys    []string // columns identifying a row across days
+	Metrics []string // columns that get _prev and _delta columns
+	Query   func(s *Server, project plane.Project, filters map[string]string) (sqlQuery, error)
+}
+
+// snapshotReports lists the reports kept by the snapshot job
+var snapshotReports = []snapshotReport{
+	{
+		Name:    "kpi_provinsi",
+		Keys:    []string{"email", "bidang"},
+		Metrics: []string{"backlog", "todo", "in_progress", "done", "percent"},
+		Query:   (*Server).kpiProvinsiQuery,
+	},
+	{
+		Name:    "kpi_kabkot",
+		Keys:    []string{"email", "bidang"},
+		Metrics: []string{"backlog", "todo", "in_progress", "done", "percent"},
+		Query:   (*Server).kpiKabkotQuery,
+	},
+	{
+		Name:    "heatmap",
+		Keys:    []string{"kab_kota", "bidang"},
+		Metrics: []string{"total", "selesai", "persen_selesai"},
+		Query:   (*Server).heatmapQuery,
+	},
+	{
+		Name:    "workload",
+		Keys:    []string{"email", "bidang"},
+		Metrics: []string{"active_issues", "completed_issues", "total_issues", "completion_rate", "avg_days_to_complete"},
+		Query:   (*Server).workloadQuery,
+	},
+}
+
+// maxCompareDays caps ?compare=
+const maxCompareDays = 365
+
+func snapshotReportByName(name string) (snapshotReport, bool) {
+	for _, rep := range snapshotReports {
+		if rep.Name == name {
+			return rep, true
+		}
+	}
+	return snapshotReport{}, false
+}
+
+// today is the current calendar day in the report timezone, as a UTC date
+func (s *Server) today() time.Time {
+	now := time.Now().In(s.CSV.location())
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// TakeSnapshots stores today's unfiltered results of every snapshot report
+// for every configured project. A failing report is logged and skipped so
+// the others are still stored; the failures are returned joined.
+func (s *Server) TakeSnapshots(ctx context.Context) error {
+	date := s.today()
+	var errs []error
+	for _, project := range s.Projects.All() {
+		failed := 0
+		for _, rep := range snapshotReports {
+			if err := s.takeSnapshot(ctx, project, rep, date); err != nil {
+				err = fmt.Errorf("snapshot %s/%s: %w", project.Key, rep.Name, err)
+				log.Printf("snapshot: %v", err)
+				errs = append(errs, err)
+				failed++
+			}
+		}
+		log.Printf("snapshot: project %s stored for %s (%d of %d reports failed)",
+			project.Key, date.Format(time.DateOnly), failed, len(snapshotReports))
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Server) takeSnapshot(ctx context.Context, project plane.Project, rep snapshotReport, date time.Time) error {
+	q, err := rep.Query(s, project, map[string]string{})
+	if err != nil {
+		return err
+	}
+	t, err := QueryTable(ctx, s.DB, q.SQL, q.Args...)
+	if err != nil {
+		return err
+	}
+
+	snap := db.Snapshot{
+		Date:        date,
+		WorkspaceID: project.WorkspaceID,
+		ProjectID:   project.ProjectID,
+		Report:      rep.Name,
+	}
+	keyCols, err := columnIndexes(t, rep.Keys)
+	if err != nil {
+		return err
+	}
+	for _, vals := range t.Rows {
+		row := make(map[string]any, len(vals))
+		for i, v := range vals {
+			row[t.Columns[i]] = jsonValue(v, t.OIDs[i])
+		}
+		b, err := json.Marshal(row)
+		if err != nil {
+			return err
+		}
+		snap.Keys = append(snap.Keys, rowKey(vals, t.OIDs, keyCols))
+		snap.Rows = append(snap.Rows, b)
+	}
+	return db.SaveSnapshot(ctx, s.DB, snap)
+}
+
+// maxSnapshotRetryDelay caps the backoff between retries of a failed
+// snapshot report
+const maxSnapshotRetryDelay = 2 * time.Hour
+
+// snapshotRetry is a project/report pair whose snapshot failed today
+type snapshotRetry struct {
+	project  plane.Project
+	rep      snapshotReport
+	attempts int
+	next     time.Time
+}
+
+// RunSnapshots takes the daily snapshot once the clock passes at (minutes
+// after midnight in the report timezone), checking every interval, until
+// ctx is done. A snapshot already stored for today is not taken again, so
+// restarts and several replicas are harmless. Pairs that fail are retried
+// on their own, first after interval and then with doubling delays up to
+// maxSnapshotRetryDelay, until the day ends.
+func (s *Server) RunSnapshots(ctx context.Context, at, interval time.Duration) {
+	var day time.Time // the day the first pass ran for
+	retries := map[string]*snapshotRetry{}
+
+	// attempt takes one snapshot unless it is already stored, and reports
+	// whether it is done for today
+	attempt := func(project plane.Project, rep snapshotReport, date time.Time) bool {
+		taken, err := db.SnapshotTaken(ctx, s.DB, project.ProjectID, []string{rep.Name}, date)
+		if err == nil && !taken {
+			err = s.takeSnapshot(ctx, project, rep, date)
+		}
+		if err != nil {
+			log.Printf("snapshot: %s/%s: %v", project.Key, rep.Name, err)
+			return false
+		}
+		return true
+	}
+
+	check := func() {
+		now := time.Now().In(s.CSV.location())
+		midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+		if now.Before(midnight.Add(at)) {
+			return
+		}
+
+		date := s.today()
+		if !date.Equal(day) {
+			// first pass of the day: every pair, failures are kept for retry
+			day = date
+			clear(retries)
+			for _, project := range s.Projects.All() {
+				failed := 0
+				for _, rep := range snapshotReports {
+					if !attempt(project, rep, date) {
+						retries[project.Key+"/"+rep.Name] = &snapshotRetry{project: project, rep: rep, attempts: 1, next: now.Add(interval)}
+						failed++
+					}
+				}
+				log.Printf("snapshot: project %s checked for %s (%d of %d reports failed)",
+					project.Key, date.Format(time.DateOnly), failed, len(snapshotReports))
+			}
+			return
+		}
+
+		for key, rt := range retries {
+			if now.Before(rt.next) {
+				continue
+			}
+			if attempt(rt.project, rt.rep, date) {
+				log.Printf("snapshot: %s stored after %d retries", key, rt.attempts)
+				delete(retries, key)
+				continue
+			}
+			delay := maxSnapshotRetryDelay
+			if rt.attempts < 16 {
+				delay = min(interval<<rt.attempts, maxSnapshotRetryDelay)
+			}
+			rt.attempts++
+			rt.next = now.Add(delay)
+		}
+	}
+
+	check()
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			check()
+		}
+	}
+}
+
+// parseCompare reads ?compare=<n>d (e.g. 7d) into the compare option
+func parseCompare(r *http.Request) (map[string]string, error) {
+	v := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("compare")))
+	if v == "" {
+		return map[string]string{}, nil
+	}
+	n, err := strconv.Atoi(strings.TrimSuffix(v, "d"))
+	if err != nil || !strings.HasSuffix(v, "d") || n < 1 || n > maxCompareDays {
+		return nil, errorStatus(http.StatusBadRequest, "invalid compare %q (expected <days>d, e.g. 7d, up to %dd)", v, maxCompareDays)
+	}
+	return map[string]string{"compare": strconv.Itoa(n) + "d"}, nil
+}
+
+// withComparison makes spec a computed report when ?compare= is set: the
+// live result gets <metric>_prev and <metric>_delta columns from the latest
+// snapshot taken on or before that many days ago, plus compare_date.
+func (s *Server) withComparison(spec reportSpec) reportSpec {
+	v := spec.Options["compare"]
+	rep, ok := snapshotReportByName(spec.Name)
+	if v == "" || !ok {
+		return spec
+	}
+	days, _ := strconv.Atoi(strings.TrimSuffix(v, "d"))
+	build := spec.Build
+
+	spec.Compute = func(ctx context.Context) (*resultTable, error) {
+		q, err := build()
+		if err != nil {
+			return nil, err
+		}
+		t, err := QueryTable(ctx, s.DB, q.SQL, q.Args...)
+		if err != nil {
+			return nil, errorStatus(http.StatusInternalServerError, "query failed: %v", err)
+		}
+		taken, prev, err := db.LoadSnapshot(ctx, s.DB, spec.Project.ProjectID, rep.Name, s.today().AddDate(0, 0, -days))
+		if err != nil {
+			return nil, errorStatus(http.StatusInternalServerError, "failed to load snapshot: %v", err)
+		}
+		if err := addComparison(t, rep, taken, prev); err != nil {
+			return nil, errorStatus(http.StatusInternalServerError, "failed to compare with snapshot: %v", err)
+		}
+		return t, nil
+	}
+	return spec
+}
+
+// addComparison appends the previous value and delta of every metric, and
+// the snapshot date, to t. Rows missing from the snapshot get empty values.
+func addComparison(t *resultTable, rep snapshotReport, taken time.Time, prev map[string]json.RawMessage) error {
+	keyCols, err := columnIndexes(t, rep.Keys)
+	if err != nil {
+		return err
+	}
+	metricCols, err := columnIndexes(t, rep.Metrics)
+	if err != nil {
+		return err
+	}
+
+	for _, i := range metricCols {
+		oid := t.OIDs[i]
+		if !isIntegerOID(oid) {
+			oid = pgtype.Float8OID
+		}
+		t.Columns = append(t.Columns, t.Columns[i]+"_prev", t.Columns[i]+"_delta")
+		t.OIDs = append(t.OIDs, oid, oid)
+	}
+	t.Columns = append(t.Columns, "compare_date")
+	t.OIDs = append(t.OIDs, pgtype.DateOID)
+
+	var compareDate any
+	if !taken.IsZero() {
+		compareDate = taken
+	}
+
+	for r, vals := range t.Rows {
+		var old map[string]json.Number
+		if b, ok := prev[rowKey(vals, t.OIDs, keyCols)]; ok {
+			dec := json.NewDecoder(strings.NewReader(string(b)))
+			dec.UseNumber()
+			var raw map[string]any
+			if err := dec.Decode(&raw); err != nil {
+				return err
+			}
+			old = map[string]json.Number{}
+			for k, v := range raw {
+				if n, ok := v.(json.Number); ok {
+					old[k] = n
+				}
+			}
+		}
+
+		for _, i := range metricCols {
+			cur, curOK := numberValue(vals[i])
+			n, prevOK := old[t.Columns[i]]
+			p, err := n.Float64()
+			if !prevOK || err != nil {
+				vals = append(vals, nil, nil)
+				continue
+			}
+			if isIntegerOID(t.OIDs[i]) {
+				var delta any
+				if curOK {
+					delta = int64(math.Round(cur - p))
+				}
+				vals = append(vals, int64(math.Round(p)), delta)
+				continue
+			}
+			var delta any
+			if curOK {
+				delta = cur - p
+			}
+			vals = append(vals, p, delta)
+		}
+		t.Rows[r] = append(vals, compareDate)
+	}
+	return nil
+}
+
+// columnIndexes finds the named columns in t
+func columnIndexes(t *resultTable, names []string) ([]int, error) {
+	idx := make([]int, len(names))
+	for n, name := range names {
+		idx[n] = -1
+		for i, col := range t.Columns {
+			if col == name {
+				idx[n] = i
+				break
+			}
+		}
+		if idx[n] < 0 {
+			return nil, fmt.Errorf("column %q not in result", name)
+		}
+	}
+	return idx, nil
+}
+
+// rowKey joins the key columns of a row into the snapshot row key
+func rowKey(vals []any, oids []uint32, keyCols []int) string {
+	parts := make([]string, len(keyCols))
+	for n, i := range keyCols {
+		if v := jsonValue(vals[i], oids[i]); v != nil {
+			parts[n] = fmt.Sprint(v)
+		}
+	}
+	return strings.Join(parts, "\x1f")
+}
+
+// numberValue converts a numeric pgx value to float64
+func numberValue(v any) (float64, bool) {
+	switch x := jsonValue(v, 0).(type) {
+	case float64:
+		return x, true
+	case int64:
+		return float64(x), true
+	case int32:
+		return float64(x), true
+	case int16:
+		return float64(x), true
+	case int:
+		return float64(x), true
+	}
+	return 0, false
+}
+
+func isIntegerOID(oid uint32) bool {
+	return oid == pgtype.Int2OID || oid == pgtype.Int4OID || oid == pgtype.Int8OID
+}
@@ -0,0 +1,321 @@
+package httpx
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/xuri/excelize/v2"
+)
+
+// resultTable is a fully read query result with the column types, used
+// where rows are needed as typed values rather than a byte stream (XLSX).
+type resultTable struct {
+	Columns []string
+	OIDs    []uint32
+	Rows    [][]any
+}
+
+// QueryTable runs the query and keeps every row in memory
+func QueryTable(ctx context.Context, pool *pgxpool.Pool, sql string, args ...any) (*resultTable, error) {
+	rows, err := pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	fields := rows.FieldDescriptions()
+	t := &resultTable{
+		Columns: make([]string, len(fields)),
+		OIDs:    make([]uint32, len(fields)),
+	}
+	for i, f := range fields {
+		t.Columns[i] = string(f.Name)
+		t.OIDs[i] = f.DataTypeOID
+	}
+
+	for rows.Next() {
+		vals, err := rows.Values()
+		if err != nil {
+			return nil, err
+		}
+		t.Rows = append(t.Rows, vals)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return t, nil
+}
+
+// xlsxSheet is one worksheet of a workbook
+type xlsxSheet struct {
+	Name  string
+	Table *resultTable
+}
+
+// statusColor is the conditional fill for one value of a status column
+type statusColor struct {
+	Value string
+	Color string
+}
+
+// statusColors lists the coloured values per status column
+var statusColors = map[string][]statusColor{
+	"deadline_status": {
+		{"Overdue", "F8CBAD"},
+		{"Warning", "FFE699"},
+		{"On Track", "C6EFCE"},
+		{"Completed", "BDD7EE"},
+		{"No Deadline", "EDEDED"},
+	},
+	"forecast_status": {
+		{"Off Track", "F8CBAD"},
+		{"At Risk", "FFE699"},
+		{"On Track", "C6EFCE"},
+		{"Selesai", "BDD7EE"},
+	},
+	"workload_status": {
+		{"Overloaded", "F8CBAD"},
+		{"Balanced", "C6EFCE"},
+		{"Underutilized", "FFE699"},
+	},
+}
+
+// maxXLSXColumnWidth caps the auto-sized column width
+const maxXLSXColumnWidth = 50
+
+// BuildXLSX renders sheets into a workbook. Cells keep their SQL types
+// (numbers, dates, timestamps in cf's timezone); every sheet gets a bold
+// frozen header row, an autofilter and conditional colouring of the
+// deadline_status/workload_status columns.
+func BuildXLSX(sheets []xlsxSheet, cf CSVFormat) ([]byte, error) {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	styles, err := newXLSXStyles(f, cf)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, sh := range sheets {
+		if i == 0 {
+			if err := f.SetSheetName("Sheet1", sh.Name); err != nil {
+				return nil, err
+			}
+		} else if _, err := f.NewSheet(sh.Name); err != nil {
+			return nil, err
+		}
+		if err := writeXLSXSheet(f, sh, styles, cf); err != nil {
+			return nil, fmt.Errorf("sheet %s: %w", sh.Name, err)
+		}
+	}
+	f.SetActiveSheet(0)
+
+	buf, err := f.WriteToBuffer()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// xlsxStyles holds the style ids shared by all sheets of a workbook
+type xlsxStyles struct {
+	header    int
+	date      int
+	timestamp int
+	number    int
+	status    map[string]int // fill colour -> conditional style id
+}
+
+func newXLSXStyles(f *excelize.File, cf CSVFormat) (*xlsxStyles, error) {
+	var s xlsxStyles
+	var err error
+
+	if s.header, err = f.NewStyle(&excelize.Style{
+		Font: &excelize.Font{Bold: true},
+		Fill: excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"D9E1F2"}},
+	}); err != nil {
+		return nil, err
+	}
+
+	dateFmt := "yyyy-mm-dd"
+	tsFmt := "yyyy-mm-dd hh:mm"
+	if s.date, err = f.NewStyle(&excelize.Style{CustomNumFmt: &dateFmt}); err != nil {
+		return nil, err
+	}
+	if s.timestamp, err = f.NewStyle(&excelize.Style{CustomNumFmt: &tsFmt}); err != nil {
+		return nil, err
+	}
+
+	numFmt := "0"
+	if cf.Precision > 0 {
+		numFmt += "." + strings.Repeat("0", cf.Precision)
+	}
+	if s.number, err = f.NewStyle(&excelize.Style{CustomNumFmt: &numFmt}); err != nil {
+		return nil, err
+	}
+
+	s.status = make(map[string]int)
+	return &s, nil
+}
+
+// statusStyle returns the conditional style for a fill colour, creating it
+// on first use
+func (s *xlsxStyles) statusStyle(f *excelize.File, color string) (int, error) {
+	if id, ok := s.status[color]; ok {
+		return id, nil
+	}
+	id, err := f.NewConditionalStyle(&excelize.Style{
+		Fill: excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{color}},
+	})
+	if err != nil {
+		return 0, err
+	}
+	s.status[color] = id
+	return id, nil
+}
+
+func writeXLSXSheet(f *excelize.File, sh xlsxSheet, styles *xlsxStyles, cf CSVFormat) error {
+	t := sh.Table
+	if len(t.Columns) == 0 {
+		return nil
+	}
+	lastCol, err := excelize.ColumnNumberToName(len(t.Columns))
+	if err != nil {
+		return err
+	}
+	lastRow := len(t.Rows) + 1
+
+	// Number formats per column type
+	widths := make([]int, len(t.Columns))
+	colStyles := make([]int, len(t.Columns))
+	for i, name := range t.Columns {
+		widths[i] = len(name)
+		switch t.OIDs[i] {
+		case pgtype.DateOID:
+			colStyles[i], widths[i] = styles.date, max(widths[i], 10)
+		case pgtype.TimestampOID, pgtype.TimestamptzOID:
+			colStyles[i], widths[i] = styles.timestamp, max(widths[i], 16)
+		case pgtype.NumericOID, pgtype.Float4OID, pgtype.Float8OID:
+			colStyles[i] = styles.number
+		}
+	}
+
+	header := make([]any, len(t.Columns))
+	for i, name := range t.Columns {
+		header[i] = name
+	}
+	if err := f.SetSheetRow(sh.Name, "A1", &header); err != nil {
+		return err
+	}
+	if err := f.SetCellStyle(sh.Name, "A1", lastCol+"1", styles.header); err != nil {
+		return err
+	}
+
+	for r, vals := range t.Rows {
+		row := make([]any, len(vals))
+		for i, v := range vals {
+			row[i] = xlsxValue(v, t.OIDs[i], cf)
+			if s, ok := row[i].(string); ok && len(s) > widths[i] {
+				widths[i] = len(s)
+			}
+		}
+		cell, _ := excelize.CoordinatesToCellName(1, r+2)
+		if err := f.SetSheetRow(sh.Name, cell, &row); err != nil {
+			return err
+		}
+	}
+
+	// Styles are applied after the values, since excelize picks its own
+	// format for time.Time cells
+	for i, w := range widths {
+		col, _ := excelize.ColumnNumberToName(i + 1)
+		if colStyles[i] != 0 && len(t.Rows) > 0 {
+			if err := f.SetCellStyle(sh.Name, col+"2", fmt.Sprintf("%s%d", col, lastRow), colStyles[i]); err != nil {
+				return err
+			}
+		}
+		if err := f.SetColWidth(sh.Name, col, col, float64(min(w+2, maxXLSXColumnWidth))); err != nil {
+			return err
+		}
+	}
+
+	// Frozen header row and autofilter over the whole table
+	if err := f.SetPanes(sh.Name, &excelize.Panes{
+		Freeze:      true,
+		YSplit:      1,
+		TopLeftCell: "A2",
+		ActivePane:  "bottomLeft",
+	}); err != nil {
+		return err
+	}
+	if err := f.AutoFilter(sh.Name, "A1:"+lastCol+fmt.Sprint(lastRow), nil); err != nil {
+		return err
+	}
+
+	// Conditional colouring of the status columns
+	if len(t.Rows) == 0 {
+		return nil
+	}
+	for i, name := range t.Columns {
+		colors, ok := statusColors[name]
+		if !ok {
+			continue
+		}
+		col, _ := excelize.ColumnNumberToName(i + 1)
+		var rules []excelize.ConditionalFormatOptions
+		for _, sc := range colors {
+			format, err := styles.statusStyle(f, sc.Color)
+			if err != nil {
+				return err
+			}
+			rules = append(rules, excelize.ConditionalFormatOptions{
+				Type:     "cell",
+				Criteria: "==",
+				Format:   &format,
+				Value:    `"` + sc.Value + `"`,
+			})
+		}
+		if err := f.SetConditionalFormat(sh.Name, fmt.Sprintf("%s2:%s%d", col, col, lastRow), rules); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// xlsxValue converts a pgx value into a typed cell value. Excel has no
+// timezones, so timestamps are written as wall-clock time in cf's timezone.
+func xlsxValue(v any, oid uint32, cf CSVFormat) any {
+	switch x := v.(type) {
+	case nil:
+		return nil
+	case time.Time:
+		if oid != pgtype.DateOID {
+			x = x.In(cf.location())
+		}
+		return time.Date(x.Year(), x.Month(), x.Day(), x.Hour(), x.Minute(), x.Second(), 0, time.UTC)
+	case pgtype.Numeric:
+		f, err := x.Float64Value()
+		if err != nil || !f.Valid || math.IsNaN(f.Float64) || math.IsInf(f.Float64, 0) {
+			return nil
+		}
+		return f.Float64
+	case float64:
+		if math.IsNaN(x) || math.IsInf(x, 0) {
+			return nil
+		}
+		return x
+	case float32:
+		return xlsxValue(float64(x), oid, cf)
+	case [16]byte:
+		return formatUUID(x)
+	case []byte:
+		return string(x)
+	default:
+		return x
+	}
+}
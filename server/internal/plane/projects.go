@@ -0,0 +1,136 @@
+package plane
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Project identifies one Plane project (and the workspace that owns it)
+// that the API is allowed to report on.
+type Project struct {
+	Key         string // short name used in ?project=
+	WorkspaceID string
+	ProjectID   string
+}
+
+// Registry is the configured set of allowed projects.
+type Registry struct {
+	projects   map[string]Project
+	defaultKey string
+}
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// keyPattern limits project keys to characters that are safe in ?project=
+// and in cache keys, which use ":" as separator
+var keyPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// NewRegistry builds a registry from projects; defaultKey selects the project
+// used when a request does not name one (empty means the first project).
+func NewRegistry(projects []Project, defaultKey string) (*Registry, error) {
+	if len(projects) == 0 {
+		return nil, fmt.Errorf("no plane project configured")
+	}
+
+	reg := &Registry{projects: map[string]Project{}}
+	for _, p := range projects {
+		if p.Key == "" {
+			return nil, fmt.Errorf("plane project without key")
+		}
+		if !keyPattern.MatchString(p.Key) {
+			return nil, fmt.Errorf("invalid project key %q (letters, digits, _ and - only)", p.Key)
+		}
+		if !uuidPattern.MatchString(p.WorkspaceID) {
+			return nil, fmt.Errorf("project %q: invalid workspace id %q", p.Key, p.WorkspaceID)
+		}
+		if !uuidPattern.MatchString(p.ProjectID) {
+			return nil, fmt.Errorf("project %q: invalid project id %q", p.Key, p.ProjectID)
+		}
+		if _, dup := reg.projects[p.Key]; dup {
+			return nil, fmt.Errorf("duplicate project key %q", p.Key)
+		}
+		reg.projects[p.Key] = p
+	}
+
+	if defaultKey == "" {
+		defaultKey = projects[0].Key
+	}
+	if _, ok := reg.projects[defaultKey]; !ok {
+		return nil, fmt.Errorf("default project %q is not configured", defaultKey)
+	}
+	reg.defaultKey = defaultKey
+
+	return reg, nil
+}
+
+// ParseProjects parses a PLANE_PROJECTS value of the form
+// "key=workspace_id/project_id,key2=workspace_id/project_id".
+func ParseProjects(s string) ([]Project, error) {
+	var out []Project
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		key, ids, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid project entry %q (want key=workspace_id/project_id)", item)
+		}
+		ws, proj, ok := strings.Cut(ids, "/")
+		if !ok {
+			return nil, fmt.Errorf("invalid project entry %q (want key=workspace_id/project_id)", item)
+		}
+		out = append(out, Project{
+			Key:         strings.TrimSpace(key),
+			WorkspaceID: strings.TrimSpace(ws),
+			ProjectID:   strings.TrimSpace(proj),
+		})
+	}
+	return out, nil
+}
+
+// Default returns the project used when a request does not name one.
+func (r *Registry) Default() Project {
+	return r.projects[r.defaultKey]
+}
+
+// Lookup returns the project for key; an empty key selects the default.
+func (r *Registry) Lookup(key string) (Project, bool) {
+	if key == "" {
+		return r.Default(), true
+	}
+	p, ok := r.projects[key]
+	return p, ok
+}
+
+// ByProjectID returns the configured project with the given Plane project id.
+func (r *Registry) ByProjectID(id string) (Project, bool) {
+	for _, p := range r.projects {
+		if strings.EqualFold(p.ProjectID, id) {
+			return p, true
+		}
+	}
+	return Project{}, false
+}
+
+// Keys returns the configured project keys in sorted order.
+func (r *Registry) Keys() []string {
+	keys := make([]string, 0, len(r.projects))
+	for k := range r.projects {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// All returns the configured projects sorted by key.
+func (r *Registry) All() []Project {
+	keys := r.Keys()
+	out := make([]Project, len(keys))
+	for i, k := range keys {
+		out[i] = r.projects[k]
+	}
+	return out
+}
@@ -1,12 +1,26 @@
 package queries
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"os"
 	"path/filepath"
+	"sync"
+	"time"
 )
 
 type Store struct {
 	BaseDir string
+
+	mu   sync.Mutex
+	sums map[string]checksum
+}
+
+// checksum is a template's hash together with the file state it was taken from
+type checksum struct {
+	modTime time.Time
+	size    int64
+	sum     string
 }
 
 func New(baseDir string) *Store {
@@ -21,3 +35,37 @@ func (s *Store) Load(name string) (string, error) {
 	}
 	return string(b), nil
 }
+
+// Checksum returns the SHA-256 of a template file, so results rendered from
+// an edited template are never mistaken for current ones. The hash is kept
+// per file and only recomputed when its mtime or size changes, so a request
+// costs one stat per template.
+func (s *Store) Checksum(name string) (string, error) {
+	p := filepath.Join(s.BaseDir, name)
+	fi, err := os.Stat(p)
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	c, ok := s.sums[name]
+	s.mu.Unlock()
+	if ok && c.modTime.Equal(fi.ModTime()) && c.size == fi.Size() {
+		return c.sum, nil
+	}
+
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.Sum256(b)
+	c = checksum{modTime: fi.ModTime(), size: fi.Size(), sum: hex.EncodeToString(h[:])}
+
+	s.mu.Lock()
+	if s.sums == nil {
+		s.sums = map[string]checksum{}
+	}
+	s.sums[name] = c
+	s.mu.Unlock()
+	return c.sum, nil
+}
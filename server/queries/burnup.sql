@@ -0,0 +1,152 @@
+-- Burnup / Burndown: total scope vs completed issues per bidang per day
+WITH
+staff_directory AS (
+  -- Directory CSV passed as parallel text[] arguments (see buildDirectoryArrays)
+  SELECT
+    email,
+    NULLIF(nama, '') AS nama,
+    NULLIF(instansi, '') AS instansi,
+    NULLIF(scope, '') AS scope,
+    NULLIF(jabatan, '') AS jabatan,
+    NULLIF(bidang, '') AS bidang,
+    NULLIF(kab_kota, '') AS kab_kota
+  FROM unnest({{DIRECTORY}}) AS t(email, nama, instansi, scope, jabatan, bidang, kab_kota)
+),
+
+wilayah AS (
+  -- Kab/kota registry from data/wilayah.csv (see buildRegionArrays)
+  SELECT kode, nama
+  FROM unnest({{WILAYAH}}) AS t(kode, nama)
+),
+
+base AS (
+  -- Deleted issues are kept: they count as scope until deleted_at
+  SELECT
+    i.id AS issue_id,
+    i.created_at::date AS created_on,
+    i.completed_at::date AS completed_on,
+    i.deleted_at::date AS deleted_on,
+    i.target_date,
+
+    ia.assignee_id,
+    COALESCE(sd.scope, 'unknown') AS scope,
+
+    -- kab/kota dari Asal Instansi di directory (utama)
+    sd.kab_kota AS kab_instansi,
+
+    -- ekstraksi kode kab/kota dari nama user Plane (fallback)
+    SUBSTRING(
+      COALESCE(u.display_name,'') || ' ' ||
+      COALESCE(u.first_name,'')   || ' ' ||
+      COALESCE(u.last_name,''),
+      {{KODE_PATTERN}}
+    ) AS kab_kode,
+
+    l.name AS bidang
+  FROM issues i
+  JOIN projects p ON p.id = i.project_id
+  JOIN workspaces w ON w.id = p.workspace_id
+  JOIN states s ON s.id = i.state_id
+  LEFT JOIN issue_assignees ia ON ia.issue_id = i.id AND ia.deleted_at IS NULL
+  LEFT JOIN users u ON u.id = ia.assignee_id
+  LEFT JOIN staff_directory sd ON sd.email = LOWER(u.email)
+  LEFT JOIN issue_labels il ON il.issue_id = i.id AND il.deleted_at IS NULL
+  LEFT JOIN labels l ON l.id = il.label_id
+  WHERE w.id = $1::uuid
+    AND p.id = $2::uuid
+    AND s."group" != 'cancelled'
+),
+attributed AS (
+  SELECT
+    b.issue_id,
+    b.created_on,
+    b.completed_on,
+    b.deleted_on,
+    b.target_date,
+    b.scope,
+    CASE
+      WHEN b.assignee_id IS NULL THEN 'Belum Ditugaskan'
+      WHEN b.scope = 'provinsi' THEN 'BPS Provinsi Kepulauan Riau'
+      WHEN b.kab_instansi IS NOT NULL THEN b.kab_instansi
+      WHEN b.kab_kode IS NULL THEN 'Kode Kab/Kota Tidak Terbaca'
+      ELSE COALESCE(wk.nama, 'Lainnya')
+    END AS kab_kota,
+    COALESCE(b.bidang, '-') AS bidang
+  FROM base b
+  LEFT JOIN wilayah wk ON wk.kode = b.kab_kode
+),
+filtered AS (
+  -- One row per issue and bidang, whatever the number of assignees
+  SELECT DISTINCT issue_id, created_on, completed_on, deleted_on, target_date, bidang
+  FROM attributed{{WHERE_CLAUSE}}
+),
+project_start AS (
+  SELECT MIN(created_on) AS start_on FROM filtered
+),
+bidang_target AS (
+  -- Ideal line: from project start to the bidang's latest target_date,
+  -- ending at the bidang's current scope
+  SELECT
+    bidang,
+    MAX(target_date) AS target_date,
+    COUNT(*) FILTER (WHERE deleted_on IS NULL) AS scope_now
+  FROM filtered
+  GROUP BY bidang
+),
+days AS (
+  SELECT d::date AS tanggal
+  FROM project_start ps,
+    generate_series(
+      GREATEST(ps.start_on, COALESCE({{FROM_DATE}}, ps.start_on)),
+      LEAST(CURRENT_DATE, COALESCE({{TO_DATE}}, CURRENT_DATE)),
+      interval '1 day'
+    ) AS d
+),
+series AS (
+  SELECT
+    d.tanggal,
+    f.bidang,
+    COUNT(*) FILTER (
+      WHERE f.created_on <= d.tanggal
+        AND (f.deleted_on IS NULL OR f.deleted_on > d.tanggal)
+    ) AS total_scope,
+    COUNT(*) FILTER (
+      WHERE f.completed_on <= d.tanggal
+        AND (f.deleted_on IS NULL OR f.deleted_on > d.tanggal)
+    ) AS completed
+  FROM days d
+  CROSS JOIN filtered f
+  GROUP BY d.tanggal, f.bidang
+),
+final AS (
+  SELECT
+    s.tanggal,
+    s.bidang,
+    s.total_scope,
+    s.completed,
+    s.total_scope - s.completed AS remaining,
+    bt.target_date,
+    bt.scope_now,
+    CASE
+      WHEN bt.target_date IS NULL THEN NULL
+      WHEN bt.target_date <= ps.start_on THEN bt.scope_now::numeric
+      ELSE ROUND(
+        bt.scope_now * LEAST(GREATEST((s.tanggal - ps.start_on)::numeric / (bt.target_date - ps.start_on), 0), 1),
+        2
+      )
+    END AS ideal_completed
+  FROM series s
+  JOIN bidang_target bt ON bt.bidang = s.bidang
+  CROSS JOIN project_start ps
+)
+SELECT
+  tanggal,
+  bidang,
+  total_scope,
+  completed,
+  remaining,
+  target_date,
+  ideal_completed,
+  scope_now - ideal_completed AS ideal_remaining
+FROM final
+ORDER BY bidang, tanggal;
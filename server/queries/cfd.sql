@@ -0,0 +1,161 @@
+-- Cumulative Flow Diagram: issues per state group per day, from issue_activities
+WITH
+staff_directory AS (
+  -- Directory CSV passed as parallel text[] arguments (see buildDirectoryArrays)
+  SELECT
+    email,
+    NULLIF(nama, '') AS nama,
+    NULLIF(instansi, '') AS instansi,
+    NULLIF(scope, '') AS scope,
+    NULLIF(jabatan, '') AS jabatan,
+    NULLIF(bidang, '') AS bidang,
+    NULLIF(kab_kota, '') AS kab_kota
+  FROM unnest({{DIRECTORY}}) AS t(email, nama, instansi, scope, jabatan, bidang, kab_kota)
+),
+
+wilayah AS (
+  -- Kab/kota registry from data/wilayah.csv (see buildRegionArrays)
+  SELECT kode, nama
+  FROM unnest({{WILAYAH}}) AS t(kode, nama)
+),
+
+base AS (
+  -- Every issue, including cancelled and deleted ones: the diagram shows
+  -- the state each issue had on each day
+  SELECT
+    i.id AS issue_id,
+    i.created_at,
+    i.deleted_at::date AS deleted_on,
+    i.state_id,
+
+    ia.assignee_id,
+    COALESCE(sd.scope, 'unknown') AS scope,
+
+    -- kab/kota dari Asal Instansi di directory (utama)
+    sd.kab_kota AS kab_instansi,
+
+    -- ekstraksi kode kab/kota dari nama user Plane (fallback)
+    SUBSTRING(
+      COALESCE(u.display_name,'') || ' ' ||
+      COALESCE(u.first_name,'')   || ' ' ||
+      COALESCE(u.last_name,''),
+      {{KODE_PATTERN}}
+    ) AS kab_kode,
+
+    l.name AS bidang
+  FROM issues i
+  JOIN projects p ON p.id = i.project_id
+  JOIN workspaces w ON w.id = p.workspace_id
+  LEFT JOIN issue_assignees ia ON ia.issue_id = i.id AND ia.deleted_at IS NULL
+  LEFT JOIN users u ON u.id = ia.assignee_id
+  LEFT JOIN staff_directory sd ON sd.email = LOWER(u.email)
+  LEFT JOIN issue_labels il ON il.issue_id = i.id AND il.deleted_at IS NULL
+  LEFT JOIN labels l ON l.id = il.label_id
+  WHERE w.id = $1::uuid
+    AND p.id = $2::uuid
+),
+attributed AS (
+  SELECT
+    b.issue_id,
+    b.created_at,
+    b.deleted_on,
+    b.state_id,
+    b.scope,
+    CASE
+      WHEN b.assignee_id IS NULL THEN 'Belum Ditugaskan'
+      WHEN b.scope = 'provinsi' THEN 'BPS Provinsi Kepulauan Riau'
+      WHEN b.kab_instansi IS NOT NULL THEN b.kab_instansi
+      WHEN b.kab_kode IS NULL THEN 'Kode Kab/Kota Tidak Terbaca'
+      ELSE COALESCE(wk.nama, 'Lainnya')
+    END AS kab_kota,
+    COALESCE(b.bidang, '-') AS bidang
+  FROM base b
+  LEFT JOIN wilayah wk ON wk.kode = b.kab_kode
+),
+filtered AS (
+  -- One row per issue, whatever the number of assignees and labels
+  SELECT DISTINCT issue_id, created_at, deleted_on, state_id
+  FROM attributed{{WHERE_CLAUSE}}
+),
+transitions AS (
+  -- State changes recorded by Plane (field = 'state', identifiers are state ids)
+  SELECT a.issue_id, a.created_at, a.old_identifier, a.new_identifier
+  FROM issue_activities a
+  JOIN filtered f ON f.issue_id = a.issue_id
+  WHERE a.field = 'state'
+    AND a.new_identifier IS NOT NULL
+    AND a.deleted_at IS NULL
+),
+changes AS (
+  -- State at creation: the old state of the first change, or the current
+  -- state when the issue never changed state
+  SELECT
+    f.issue_id,
+    f.created_at,
+    COALESCE(
+      (SELECT t.old_identifier FROM transitions t WHERE t.issue_id = f.issue_id ORDER BY t.created_at LIMIT 1),
+      f.state_id
+    ) AS state_id
+  FROM filtered f
+  UNION ALL
+  SELECT issue_id, created_at, new_identifier
+  FROM transitions
+),
+daily_state AS (
+  -- State at the end of each day an issue changed
+  SELECT DISTINCT ON (issue_id, created_at::date)
+    issue_id,
+    created_at::date AS changed_on,
+    state_id
+  FROM changes
+  ORDER BY issue_id, created_at::date, created_at DESC
+),
+intervals AS (
+  SELECT
+    issue_id,
+    state_id,
+    changed_on AS valid_from,
+    LEAD(changed_on) OVER (PARTITION BY issue_id ORDER BY changed_on) AS valid_until
+  FROM daily_state
+),
+project_start AS (
+  SELECT MIN(created_at)::date AS start_on FROM filtered
+),
+days AS (
+  SELECT d::date AS tanggal
+  FROM project_start ps,
+    generate_series(
+      GREATEST(ps.start_on, COALESCE({{FROM_DATE}}, ps.start_on)),
+      LEAST(CURRENT_DATE, COALESCE({{TO_DATE}}, CURRENT_DATE)),
+      interval '1 day'
+    ) AS d
+),
+counts AS (
+  SELECT
+    d.tanggal,
+    COUNT(*) FILTER (WHERE s."group" = 'backlog') AS backlog,
+    COUNT(*) FILTER (WHERE s."group" = 'unstarted') AS todo,
+    COUNT(*) FILTER (WHERE s."group" IN ('started', 'triage')) AS in_progress,
+    COUNT(*) FILTER (WHERE s."group" = 'completed') AS done,
+    COUNT(*) FILTER (WHERE s."group" = 'cancelled') AS cancelled
+  FROM days d
+  JOIN intervals iv
+    ON iv.valid_from <= d.tanggal
+   AND (iv.valid_until IS NULL OR iv.valid_until > d.tanggal)
+  JOIN filtered f
+    ON f.issue_id = iv.issue_id
+   AND (f.deleted_on IS NULL OR f.deleted_on > d.tanggal)
+  JOIN states s ON s.id = iv.state_id
+  GROUP BY d.tanggal
+)
+SELECT
+  d.tanggal,
+  COALESCE(c.backlog, 0) AS backlog,
+  COALESCE(c.todo, 0) AS todo,
+  COALESCE(c.in_progress, 0) AS in_progress,
+  COALESCE(c.done, 0) AS done,
+  COALESCE(c.cancelled, 0) AS cancelled,
+  COALESCE(c.backlog + c.todo + c.in_progress + c.done, 0) AS total_active
+FROM days d
+LEFT JOIN counts c ON c.tanggal = d.tanggal
+ORDER BY d.tanggal;
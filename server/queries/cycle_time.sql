@@ -0,0 +1,149 @@
+-- Cycle Time & Lead Time: percentiles or histogram of completed issues
+WITH
+staff_directory AS (
+  -- Directory CSV passed as parallel text[] arguments (see buildDirectoryArrays)
+  SELECT
+    email,
+    NULLIF(nama, '') AS nama,
+    NULLIF(instansi, '') AS instansi,
+    NULLIF(scope, '') AS scope,
+    NULLIF(jabatan, '') AS jabatan,
+    NULLIF(bidang, '') AS bidang,
+    NULLIF(kab_kota, '') AS kab_kota
+  FROM unnest({{DIRECTORY}}) AS t(email, nama, instansi, scope, jabatan, bidang, kab_kota)
+),
+
+wilayah AS (
+  -- Kab/kota registry from data/wilayah.csv (see buildRegionArrays)
+  SELECT kode, nama
+  FROM unnest({{WILAYAH}}) AS t(kode, nama)
+),
+
+first_started AS (
+  -- Cycle time starts at the first move into a 'started' state
+  SELECT a.issue_id, MIN(a.created_at) AS started_at
+  FROM issue_activities a
+  JOIN states st ON st.id = a.new_identifier
+  WHERE a.project_id = $2::uuid
+    AND a.field = 'state'
+    AND a.deleted_at IS NULL
+    AND st."group" = 'started'
+  GROUP BY a.issue_id
+),
+
+base AS (
+  -- Completed issues only
+  SELECT
+    i.id AS issue_id,
+    i.created_at,
+    i.completed_at,
+    fs.started_at,
+
+    ia.assignee_id,
+    COALESCE(sd.nama, u.display_name, u.first_name || ' ' || u.last_name, '-') AS nama,
+    COALESCE(LOWER(u.email), '-') AS email,
+    COALESCE(sd.instansi, '-') AS instansi,
+    COALESCE(sd.scope, 'unknown') AS scope,
+
+    -- kab/kota dari Asal Instansi di directory (utama)
+    sd.kab_kota AS kab_instansi,
+
+    -- ekstraksi kode kab/kota dari nama user Plane (fallback)
+    SUBSTRING(
+      COALESCE(u.display_name,'') || ' ' ||
+      COALESCE(u.first_name,'')   || ' ' ||
+      COALESCE(u.last_name,''),
+      {{KODE_PATTERN}}
+    ) AS kab_kode,
+
+    l.name AS bidang
+  FROM issues i
+  JOIN projects p ON p.id = i.project_id
+  JOIN workspaces w ON w.id = p.workspace_id
+  JOIN states s ON s.id = i.state_id
+  LEFT JOIN first_started fs ON fs.issue_id = i.id
+  LEFT JOIN issue_assignees ia ON ia.issue_id = i.id AND ia.deleted_at IS NULL
+  LEFT JOIN users u ON u.id = ia.assignee_id
+  LEFT JOIN staff_directory sd ON sd.email = LOWER(u.email)
+  LEFT JOIN issue_labels il ON il.issue_id = i.id AND il.deleted_at IS NULL
+  LEFT JOIN labels l ON l.id = il.label_id
+  WHERE w.id = $1::uuid
+    AND p.id = $2::uuid
+    AND i.deleted_at IS NULL
+    AND s."group" = 'completed'
+    AND i.completed_at IS NOT NULL
+    AND i.completed_at::date >= COALESCE({{FROM_DATE}}, '-infinity'::date)
+    AND i.completed_at::date <= COALESCE({{TO_DATE}}, 'infinity'::date)
+),
+attributed AS (
+  -- Group columns are never NULL, so they can be joined with USING
+  SELECT
+    b.issue_id,
+    b.nama,
+    b.email,
+    b.instansi,
+    b.scope,
+    CASE
+      WHEN b.assignee_id IS NULL THEN 'Belum Ditugaskan'
+      WHEN b.scope = 'provinsi' THEN 'BPS Provinsi Kepulauan Riau'
+      WHEN b.kab_instansi IS NOT NULL THEN b.kab_instansi
+      WHEN b.kab_kode IS NULL THEN 'Kode Kab/Kota Tidak Terbaca'
+      ELSE COALESCE(wk.nama, 'Lainnya')
+    END AS kab_kota,
+    COALESCE(b.bidang, '-') AS bidang,
+    CASE
+      WHEN b.started_at IS NOT NULL AND b.started_at <= b.completed_at
+      THEN EXTRACT(EPOCH FROM (b.completed_at - b.started_at)) / 86400.0
+    END AS cycle_days,
+    EXTRACT(EPOCH FROM (b.completed_at - b.created_at)) / 86400.0 AS lead_days
+  FROM base b
+  LEFT JOIN wilayah wk ON wk.kode = b.kab_kode
+),
+durations AS (
+  -- One row per issue and group, whatever the number of assignees and labels
+  SELECT DISTINCT {{GROUP_COLUMNS}}, issue_id, cycle_days, lead_days
+  FROM (SELECT * FROM attributed{{WHERE_CLAUSE}}) a
+),
+
+percentiles AS (
+  SELECT
+    {{GROUP_COLUMNS}},
+    COUNT(*) AS completed_issues,
+    COUNT(cycle_days) AS with_cycle_time,
+    ROUND((percentile_cont(0.50) WITHIN GROUP (ORDER BY cycle_days))::numeric, 2) AS cycle_p50,
+    ROUND((percentile_cont(0.75) WITHIN GROUP (ORDER BY cycle_days))::numeric, 2) AS cycle_p75,
+    ROUND((percentile_cont(0.90) WITHIN GROUP (ORDER BY cycle_days))::numeric, 2) AS cycle_p90,
+    ROUND((percentile_cont(0.50) WITHIN GROUP (ORDER BY lead_days))::numeric, 2) AS lead_p50,
+    ROUND((percentile_cont(0.75) WITHIN GROUP (ORDER BY lead_days))::numeric, 2) AS lead_p75,
+    ROUND((percentile_cont(0.90) WITHIN GROUP (ORDER BY lead_days))::numeric, 2) AS lead_p90
+  FROM durations
+  GROUP BY {{GROUP_COLUMNS}}
+),
+
+buckets AS (
+  -- Histogram buckets from ?buckets= (see parseBuckets); the last one is open ended
+  SELECT bucket, bucket_from, bucket_to
+  FROM unnest({{BUCKETS}}) AS t(bucket, bucket_from, bucket_to)
+),
+metric_days AS (
+  SELECT {{GROUP_COLUMNS}}, 'cycle' AS metric, cycle_days AS days FROM durations WHERE cycle_days IS NOT NULL
+  UNION ALL
+  SELECT {{GROUP_COLUMNS}}, 'lead' AS metric, lead_days AS days FROM durations
+),
+histogram AS (
+  SELECT
+    {{GROUP_COLUMNS}},
+    metric,
+    b.bucket,
+    b.bucket_from,
+    NULLIF(b.bucket_to, 'Infinity'::float8) AS bucket_to,
+    COUNT(md.days) FILTER (WHERE md.days >= b.bucket_from AND md.days < b.bucket_to) AS issues
+  FROM (SELECT DISTINCT {{GROUP_COLUMNS}} FROM durations) g
+  CROSS JOIN (VALUES ('cycle'), ('lead')) AS m(metric)
+  CROSS JOIN buckets b
+  LEFT JOIN metric_days md USING ({{GROUP_COLUMNS}}, metric)
+  GROUP BY {{GROUP_COLUMNS}}, metric, b.bucket, b.bucket_from, b.bucket_to
+)
+-- Result CTE chosen by ?mode=; the unused one is never evaluated
+SELECT * FROM {{RESULT}}
+ORDER BY {{ORDER_BY}};
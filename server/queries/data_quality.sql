@@ -0,0 +1,167 @@
+-- Data Quality: issues yang tidak bisa diatribusikan dengan benar, beserta alasannya
+WITH
+staff_directory AS (
+  -- Directory CSV passed as parallel text[] arguments (see buildDirectoryArrays)
+  SELECT
+    email,
+    NULLIF(nama, '') AS nama,
+    NULLIF(instansi, '') AS instansi,
+    NULLIF(scope, '') AS scope,
+    NULLIF(jabatan, '') AS jabatan,
+    NULLIF(bidang, '') AS bidang,
+    NULLIF(kab_kota, '') AS kab_kota
+  FROM unnest({{DIRECTORY}}) AS t(email, nama, instansi, scope, jabatan, bidang, kab_kota)
+),
+
+directory_members AS (
+  -- Every email in the directory CSV, including staff the KPI reports do not
+  -- list (anggota kab/kota, unknown jabatan)
+  SELECT email
+  FROM unnest({{MEMBERS}}) AS t(email)
+),
+
+wilayah AS (
+  -- Kab/kota registry from data/wilayah.csv (see buildRegionArrays)
+  SELECT kode, nama
+  FROM unnest({{WILAYAH}}) AS t(kode, nama)
+),
+
+issue_base AS (
+  SELECT
+    i.id AS issue_id,
+    i.sequence_id,
+    i.name AS issue_title,
+    s."group" AS status
+  FROM issues i
+  JOIN projects p ON p.id = i.project_id
+  JOIN workspaces w ON w.id = p.workspace_id
+  JOIN states s ON s.id = i.state_id
+  WHERE w.id = $1::uuid
+    AND p.id = $2::uuid
+    AND i.deleted_at IS NULL
+    AND s."group" != 'cancelled'
+),
+issue_label_names AS (
+  SELECT il.issue_id, l.name
+  FROM issue_labels il
+  JOIN labels l ON l.id = il.label_id
+  JOIN issue_base b ON b.issue_id = il.issue_id
+  WHERE il.deleted_at IS NULL
+),
+label_agg AS (
+  SELECT issue_id, ARRAY_AGG(name ORDER BY name) AS labels
+  FROM issue_label_names
+  GROUP BY issue_id
+),
+assignees AS (
+  SELECT
+    ia.issue_id,
+    LOWER(u.email) AS email,
+    COALESCE(sd.nama, u.display_name, u.first_name || ' ' || u.last_name, '-') AS nama,
+    u.display_name,
+    EXISTS (SELECT 1 FROM directory_members dm WHERE dm.email = LOWER(u.email)) AS in_directory,
+    sd.scope,
+    sd.kab_kota AS kab_instansi,
+
+    -- ekstraksi kode kab/kota dari nama user Plane (sama dengan report lain)
+    SUBSTRING(
+      COALESCE(u.display_name,'') || ' ' ||
+      COALESCE(u.first_name,'')   || ' ' ||
+      COALESCE(u.last_name,''),
+      {{KODE_PATTERN}}
+    ) AS kab_kode
+  FROM issue_assignees ia
+  JOIN issue_base b ON b.issue_id = ia.issue_id
+  JOIN users u ON u.id = ia.assignee_id
+  LEFT JOIN staff_directory sd ON sd.email = LOWER(u.email)
+  WHERE ia.deleted_at IS NULL
+),
+directory_bidang AS (
+  SELECT DISTINCT bidang FROM staff_directory WHERE bidang IS NOT NULL
+),
+findings AS (
+  SELECT b.issue_id, NULL::text AS email, NULL::text AS nama,
+    'no_assignee' AS reason_code,
+    'Issue belum punya assignee' AS reason,
+    'kab_kota: Belum Ditugaskan' AS bucket,
+    NULL::text AS detail
+  FROM issue_base b
+  WHERE NOT EXISTS (SELECT 1 FROM assignees a WHERE a.issue_id = b.issue_id)
+
+  UNION ALL
+  SELECT a.issue_id, a.email, a.nama,
+    'assignee_not_in_directory',
+    'Email assignee tidak ada di directory',
+    'scope: unknown',
+    a.email
+  FROM assignees a
+  WHERE NOT a.in_directory
+
+  UNION ALL
+  -- Only when the directory gives no kab/kota either, i.e. the code decides
+  SELECT a.issue_id, a.email, a.nama,
+    'no_kode_kabkota',
+    'Nama assignee di Plane tidak mengandung kode 21xx',
+    'kab_kota: Kode Kab/Kota Tidak Terbaca',
+    a.display_name
+  FROM assignees a
+  WHERE COALESCE(a.scope, '') != 'provinsi'
+    AND a.kab_instansi IS NULL
+    AND a.kab_kode IS NULL
+
+  UNION ALL
+  SELECT a.issue_id, a.email, a.nama,
+    'unknown_kode_kabkota',
+    'Kode kab/kota di nama assignee tidak ada di wilayah',
+    'kab_kota: Lainnya',
+    a.kab_kode
+  FROM assignees a
+  WHERE COALESCE(a.scope, '') != 'provinsi'
+    AND a.kab_instansi IS NULL
+    AND a.kab_kode IS NOT NULL
+    AND NOT EXISTS (SELECT 1 FROM wilayah wk WHERE wk.kode = a.kab_kode)
+
+  UNION ALL
+  SELECT b.issue_id, NULL, NULL,
+    'no_bidang_label',
+    'Issue tidak punya label bidang',
+    'bidang: -',
+    NULL
+  FROM issue_base b
+  WHERE NOT EXISTS (SELECT 1 FROM label_agg la WHERE la.issue_id = b.issue_id)
+
+  UNION ALL
+  SELECT la.issue_id, NULL, NULL,
+    'multiple_bidang_labels',
+    'Issue punya lebih dari satu label bidang (dihitung di setiap bidang)',
+    'bidang: ganda',
+    array_to_string(la.labels, ', ')
+  FROM label_agg la
+  WHERE cardinality(la.labels) > 1
+
+  UNION ALL
+  SELECT ln.issue_id, NULL, NULL,
+    'unknown_bidang_label',
+    'Label tidak cocok dengan bidang mana pun di directory',
+    'bidang: ' || ln.name,
+    ln.name
+  FROM issue_label_names ln
+  WHERE NOT EXISTS (SELECT 1 FROM directory_bidang db WHERE db.bidang = ln.name)
+),
+final AS (
+  SELECT
+    b.sequence_id,
+    b.issue_title,
+    b.status,
+    f.nama AS assignee,
+    f.email AS assignee_email,
+    f.reason_code AS reason,
+    f.reason AS keterangan,
+    f.bucket,
+    f.detail,
+    b.issue_id
+  FROM findings f
+  JOIN issue_base b ON b.issue_id = f.issue_id
+)
+SELECT * FROM final{{WHERE_CLAUSE}}
+ORDER BY reason, sequence_id, assignee_email;
@@ -0,0 +1,112 @@
+-- Forecast input: open issues, latest target_date and weekly throughput per group
+-- (the Monte Carlo simulation runs in Go, see internal/forecast)
+WITH
+staff_directory AS (
+  -- Directory CSV passed as parallel text[] arguments (see buildDirectoryArrays)
+  SELECT
+    email,
+    NULLIF(nama, '') AS nama,
+    NULLIF(instansi, '') AS instansi,
+    NULLIF(scope, '') AS scope,
+    NULLIF(jabatan, '') AS jabatan,
+    NULLIF(bidang, '') AS bidang,
+    NULLIF(kab_kota, '') AS kab_kota
+  FROM unnest({{DIRECTORY}}) AS t(email, nama, instansi, scope, jabatan, bidang, kab_kota)
+),
+
+wilayah AS (
+  -- Kab/kota registry from data/wilayah.csv (see buildRegionArrays)
+  SELECT kode, nama
+  FROM unnest({{WILAYAH}}) AS t(kode, nama)
+),
+
+base AS (
+  SELECT
+    i.id AS issue_id,
+    CASE WHEN s."group" = 'completed' THEN i.completed_at::date END AS completed_on,
+    s."group" NOT IN ('completed', 'cancelled') AS is_open,
+    i.target_date,
+
+    ia.assignee_id,
+    COALESCE(sd.scope, 'unknown') AS scope,
+
+    -- kab/kota dari Asal Instansi di directory (utama)
+    sd.kab_kota AS kab_instansi,
+
+    -- ekstraksi kode kab/kota dari nama user Plane (fallback)
+    SUBSTRING(
+      COALESCE(u.display_name,'') || ' ' ||
+      COALESCE(u.first_name,'')   || ' ' ||
+      COALESCE(u.last_name,''),
+      {{KODE_PATTERN}}
+    ) AS kab_kode,
+
+    l.name AS bidang
+  FROM issues i
+  JOIN projects p ON p.id = i.project_id
+  JOIN workspaces w ON w.id = p.workspace_id
+  JOIN states s ON s.id = i.state_id
+  LEFT JOIN issue_assignees ia ON ia.issue_id = i.id AND ia.deleted_at IS NULL
+  LEFT JOIN users u ON u.id = ia.assignee_id
+  LEFT JOIN staff_directory sd ON sd.email = LOWER(u.email)
+  LEFT JOIN issue_labels il ON il.issue_id = i.id AND il.deleted_at IS NULL
+  LEFT JOIN labels l ON l.id = il.label_id
+  WHERE w.id = $1::uuid
+    AND p.id = $2::uuid
+    AND i.deleted_at IS NULL
+    AND s."group" != 'cancelled'
+),
+attributed AS (
+  SELECT
+    b.issue_id,
+    b.completed_on,
+    b.is_open,
+    b.target_date,
+    b.scope,
+    CASE
+      WHEN b.assignee_id IS NULL THEN 'Belum Ditugaskan'
+      WHEN b.scope = 'provinsi' THEN 'BPS Provinsi Kepulauan Riau'
+      WHEN b.kab_instansi IS NOT NULL THEN b.kab_instansi
+      WHEN b.kab_kode IS NULL THEN 'Kode Kab/Kota Tidak Terbaca'
+      ELSE COALESCE(wk.nama, 'Lainnya')
+    END AS kab_kota,
+    COALESCE(b.bidang, '-') AS bidang
+  FROM base b
+  LEFT JOIN wilayah wk ON wk.kode = b.kab_kode
+),
+filtered AS (
+  -- One row per issue and group, whatever the number of assignees and labels
+  SELECT DISTINCT {{GROUP_COLUMN}} AS grp, issue_id, completed_on, is_open, target_date
+  FROM (SELECT * FROM attributed{{WHERE_CLAUSE}}) a
+),
+groups AS (
+  SELECT
+    grp,
+    COUNT(*) FILTER (WHERE is_open) AS remaining,
+    MAX(target_date) AS target_date
+  FROM filtered
+  GROUP BY grp
+),
+weekly AS (
+  -- Week n covers the 7 days ending n weeks before today
+  SELECT
+    g.grp,
+    wk.n,
+    COUNT(f.issue_id) AS completed
+  FROM groups g
+  CROSS JOIN generate_series(0, {{WEEKS}} - 1) AS wk(n)
+  LEFT JOIN filtered f
+    ON f.grp = g.grp
+   AND f.completed_on >  CURRENT_DATE - 7 * (wk.n + 1)
+   AND f.completed_on <= CURRENT_DATE - 7 * wk.n
+  GROUP BY g.grp, wk.n
+)
+SELECT
+  g.grp,
+  g.remaining,
+  g.target_date,
+  ARRAY_AGG(w.completed ORDER BY w.n DESC) AS weekly
+FROM groups g
+JOIN weekly w ON w.grp = g.grp
+GROUP BY g.grp, g.remaining, g.target_date
+ORDER BY g.grp;
@@ -1,14 +1,32 @@
 -- Heatmap: Kabupaten/Kota × Bidang matrix
-WITH base AS (
+WITH 
+staff_directory AS (
+  -- Directory CSV passed as parallel text[] arguments (see buildDirectoryArrays)
+  SELECT
+    email,
+    NULLIF(kab_kota, '') AS kab_kota
+  FROM unnest({{DIRECTORY}}) AS t(email, nama, instansi, scope, jabatan, bidang, kab_kota)
+),
+
+wilayah AS (
+  -- Kab/kota registry from data/wilayah.csv (see buildRegionArrays)
+  SELECT kode, nama
+  FROM unnest({{WILAYAH}}) AS t(kode, nama)
+),
+
+base AS (
   SELECT
     s."group" AS status,
     l.name AS bidang,
     ia.assignee_id,
+    -- kab/kota dari Asal Instansi di directory (utama)
+    sd.kab_kota AS kab_instansi,
+    -- ekstraksi kode kab/kota dari nama user Plane (fallback)
     SUBSTRING(
       COALESCE(u.display_name,'') || ' ' ||
       COALESCE(u.first_name,'')   || ' ' ||
       COALESCE(u.last_name,''),
-      '(21[0-9]{2})'
+      {{KODE_PATTERN}}
     ) AS kab_kode
   FROM issues i
   JOIN projects p ON p.id = i.project_id
@@ -16,30 +34,26 @@ WITH base AS (
   JOIN states s ON s.id = i.state_id
   LEFT JOIN issue_assignees ia ON ia.issue_id = i.id AND ia.deleted_at IS NULL
   LEFT JOIN users u ON u.id = ia.assignee_id
+  LEFT JOIN staff_directory sd ON sd.email = LOWER(u.email)
   JOIN issue_labels il ON il.issue_id = i.id AND il.deleted_at IS NULL
   JOIN labels l ON l.id = il.label_id
-  WHERE w.id = '58f6ec9b-f0ae-4e68-8f05-8f1d9ddf9cac'::uuid
-    AND p.id = 'cfc12151-e169-4caf-bca9-3eb83ed588ee'::uuid
+  WHERE w.id = $1::uuid
+    AND p.id = $2::uuid
     AND i.deleted_at IS NULL
     AND s."group" != 'cancelled'
 ),
 data AS (
   SELECT
     CASE
-      WHEN assignee_id IS NULL THEN 'Belum Ditugaskan'
-      WHEN kab_kode IS NULL THEN 'Kode Kab/Kota Tidak Terbaca'
-      WHEN kab_kode = '2101' THEN 'Karimun'
-      WHEN kab_kode = '2102' THEN 'Bintan'
-      WHEN kab_kode = '2103' THEN 'Natuna'
-      WHEN kab_kode = '2104' THEN 'Lingga'
-      WHEN kab_kode = '2105' THEN 'Kep. Anambas'
-      WHEN kab_kode = '2171' THEN 'Batam'
-      WHEN kab_kode = '2172' THEN 'Tanjung Pinang'
-      ELSE 'Lainnya'
+      WHEN b.assignee_id IS NULL THEN 'Belum Ditugaskan'
+      WHEN b.kab_instansi IS NOT NULL THEN b.kab_instansi
+      WHEN b.kab_kode IS NULL THEN 'Kode Kab/Kota Tidak Terbaca'
+      ELSE COALESCE(wk.nama, 'Lainnya')
     END AS kab_kota,
-    bidang,
-    status
-  FROM base
+    b.bidang,
+    b.status
+  FROM base b
+  LEFT JOIN wilayah wk ON wk.kode = b.kab_kode
 )
 SELECT
   kab_kota,
@@ -1,5 +1,24 @@
 -- Issues Detail: Get ketua_bidang nama from CSV (Provinsi + Kabkot)
 WITH 
+staff_directory AS (
+  -- Directory CSV passed as parallel text[] arguments (see buildDirectoryArrays)
+  SELECT
+    email,
+    NULLIF(nama, '') AS nama,
+    NULLIF(instansi, '') AS instansi,
+    NULLIF(scope, '') AS scope,
+    NULLIF(jabatan, '') AS jabatan,
+    NULLIF(bidang, '') AS bidang,
+    NULLIF(kab_kota, '') AS kab_kota
+  FROM unnest({{DIRECTORY}}) AS t(email, nama, instansi, scope, jabatan, bidang, kab_kota)
+),
+
+wilayah AS (
+  -- Kab/kota registry from data/wilayah.csv (see buildRegionArrays)
+  SELECT kode, nama
+  FROM unnest({{WILAYAH}}) AS t(kode, nama)
+),
+
 base AS (
   SELECT
     i.id AS issue_id,
@@ -12,27 +31,25 @@ base AS (
     u.email AS assignee_email,
 
     -- Get nama from CSV, fallback to users table
-    CASE
-{{NAMA_CASES}}
-      ELSE COALESCE(
-        u.display_name,
-        NULLIF(TRIM(COALESCE(u.first_name,'') || ' ' || COALESCE(u.last_name,'')), ''),
-        '-'
-      )
-    END AS assignee_name,
+    COALESCE(
+      sd.nama,
+      u.display_name,
+      NULLIF(TRIM(COALESCE(u.first_name,'') || ' ' || COALESCE(u.last_name,'')), ''),
+      '-'
+    ) AS assignee_name,
 
     -- Get scope from CSV
-    CASE
-{{SCOPE_CASES}}
-      ELSE 'unknown'
-    END AS scope,
+    COALESCE(sd.scope, 'unknown') AS scope,
+
+    -- kab/kota dari Asal Instansi di directory (utama)
+    sd.kab_kota AS kab_instansi,
 
-    -- ekstraksi kode kab/kota
+    -- ekstraksi kode kab/kota dari nama user Plane (fallback)
     SUBSTRING(
       COALESCE(u.display_name,'') || ' ' ||
       COALESCE(u.first_name,'')   || ' ' ||
       COALESCE(u.last_name,''),
-      '(21[0-9]{2})'
+      {{KODE_PATTERN}}
     ) AS kab_kode,
 
     l.name AS bidang
@@ -42,10 +59,11 @@ base AS (
   JOIN states s ON s.id = i.state_id
   LEFT JOIN issue_assignees ia ON ia.issue_id = i.id AND ia.deleted_at IS NULL
   LEFT JOIN users u ON u.id = ia.assignee_id
+  LEFT JOIN staff_directory sd ON sd.email = LOWER(u.email)
   LEFT JOIN issue_labels il ON il.issue_id = i.id AND il.deleted_at IS NULL
   LEFT JOIN labels l ON l.id = il.label_id
-  WHERE w.id = '58f6ec9b-f0ae-4e68-8f05-8f1d9ddf9cac'::uuid
-    AND p.id = 'cfc12151-e169-4caf-bca9-3eb83ed588ee'::uuid
+  WHERE w.id = $1::uuid
+    AND p.id = $2::uuid
     AND i.deleted_at IS NULL
     AND s."group" != 'cancelled'
 ),
@@ -64,15 +82,9 @@ final AS (
     CASE
       WHEN b.assignee_id IS NULL THEN 'Belum Ditugaskan'
       WHEN b.scope = 'provinsi' THEN 'BPS Provinsi Kepulauan Riau'
+      WHEN b.kab_instansi IS NOT NULL THEN b.kab_instansi
       WHEN b.kab_kode IS NULL THEN 'Kode Kab/Kota Tidak Terbaca'
-      WHEN b.kab_kode = '2101' THEN 'Karimun'
-      WHEN b.kab_kode = '2102' THEN 'Bintan'
-      WHEN b.kab_kode = '2103' THEN 'Natuna'
-      WHEN b.kab_kode = '2104' THEN 'Lingga'
-      WHEN b.kab_kode = '2105' THEN 'Kep. Anambas'
-      WHEN b.kab_kode = '2171' THEN 'Batam'
-      WHEN b.kab_kode = '2172' THEN 'Tanjung Pinang'
-      ELSE 'Lainnya'
+      ELSE COALESCE(wk.nama, 'Lainnya')
     END AS kab_kota,
     COALESCE(b.bidang, '-') AS bidang,
     b.status,
@@ -83,6 +95,7 @@ final AS (
     lc.last_comment,
     lc.comment_time
   FROM base b
+  LEFT JOIN wilayah wk ON wk.kode = b.kab_kode
   LEFT JOIN latest_comment lc ON lc.issue_id = b.issue_id
 )
 SELECT * FROM final{{WHERE_CLAUSE}}
@@ -1,24 +1,28 @@
 -- KPI Provinsi: Dynamic from CSV
 WITH 
+staff_directory AS (
+  -- Directory CSV passed as parallel text[] arguments (see buildDirectoryArrays)
+  SELECT
+    email,
+    NULLIF(nama, '') AS nama,
+    NULLIF(instansi, '') AS instansi,
+    NULLIF(scope, '') AS scope,
+    NULLIF(jabatan, '') AS jabatan,
+    NULLIF(bidang, '') AS bidang,
+    NULLIF(kab_kota, '') AS kab_kota
+  FROM unnest({{DIRECTORY}}) AS t(email, nama, instansi, scope, jabatan, bidang, kab_kota)
+),
+
 directory AS (
   SELECT 
     u.id AS user_id,
     LOWER(u.email) AS email,
-    CASE
-{{NAMA_CASES}}
-      ELSE COALESCE(u.display_name, u.first_name || ' ' || u.last_name, '-')
-    END AS nama,
+    COALESCE(sd.nama, u.display_name, u.first_name || ' ' || u.last_name, '-') AS nama,
     'BPS Provinsi Kepulauan Riau' AS instansi,
-    CASE
-{{JABATAN_CASES}}
-      ELSE 'Anggota'
-    END AS jabatan,
-    CASE
-{{BIDANG_CASES}}
-      ELSE NULL
-    END AS bidang
+    COALESCE(sd.jabatan, 'Anggota') AS jabatan,
+    sd.bidang
   FROM users u
-  WHERE LOWER(u.email) IN ({{EMAILS}})
+  JOIN staff_directory sd ON sd.email = LOWER(u.email)
 ),
 
 issue_agg AS (
@@ -36,8 +40,8 @@ issue_agg AS (
   JOIN issue_assignees ia ON ia.issue_id = i.id AND ia.deleted_at IS NULL
   JOIN issue_labels il ON il.issue_id = i.id AND il.deleted_at IS NULL
   JOIN labels l ON l.id = il.label_id
-  WHERE w.id = '58f6ec9b-f0ae-4e68-8f05-8f1d9ddf9cac'
-    AND p.id = 'cfc12151-e169-4caf-bca9-3eb83ed588ee'
+  WHERE w.id = $1::uuid
+    AND p.id = $2::uuid
     AND i.deleted_at IS NULL
     AND s."group" != 'cancelled'
   GROUP BY ia.assignee_id, l.name
@@ -0,0 +1,12 @@
+-- Last Modified: waktu perubahan terakhir data project
+-- (issue terakhir diubah atau komentar terakhir dibuat), untuk header Last-Modified
+SELECT GREATEST(
+  (SELECT MAX(i.updated_at)
+   FROM issues i
+   WHERE i.workspace_id = $1::uuid
+     AND i.project_id = $2::uuid),
+  (SELECT MAX(ic.created_at)
+   FROM issue_comments ic
+   WHERE ic.workspace_id = $1::uuid
+     AND ic.project_id = $2::uuid)
+) AS last_modified;
@@ -0,0 +1,46 @@
+-- Notify Triggers: kirim pg_notify setiap kali issue, assignee, label atau
+-- komentar berubah, agar API bisa meng-invalidasi cache tanpa menunggu TTL.
+-- {{CHANNEL}} diganti dengan nama channel (NOTIFY_CHANNEL) sebagai literal SQL.
+-- Install: ./api -install-triggers (idempotent, aman dijalankan ulang)
+
+CREATE OR REPLACE FUNCTION serumpun_notify_change() RETURNS trigger
+LANGUAGE plpgsql AS $$
+DECLARE
+  rec record;
+BEGIN
+  IF TG_OP = 'DELETE' THEN
+    rec := OLD;
+  ELSE
+    rec := NEW;
+  END IF;
+
+  -- payload identik dalam satu transaksi digabung oleh Postgres
+  PERFORM pg_notify({{CHANNEL}}, json_build_object(
+    'table', TG_TABLE_NAME,
+    'op', TG_OP,
+    'workspace_id', rec.workspace_id,
+    'project_id', rec.project_id
+  )::text);
+  RETURN NULL;
+END;
+$$;
+
+DROP TRIGGER IF EXISTS serumpun_notify_change ON issues;
+CREATE TRIGGER serumpun_notify_change
+  AFTER INSERT OR UPDATE OR DELETE ON issues
+  FOR EACH ROW EXECUTE FUNCTION serumpun_notify_change();
+
+DROP TRIGGER IF EXISTS serumpun_notify_change ON issue_assignees;
+CREATE TRIGGER serumpun_notify_change
+  AFTER INSERT OR UPDATE OR DELETE ON issue_assignees
+  FOR EACH ROW EXECUTE FUNCTION serumpun_notify_change();
+
+DROP TRIGGER IF EXISTS serumpun_notify_change ON issue_labels;
+CREATE TRIGGER serumpun_notify_change
+  AFTER INSERT OR UPDATE OR DELETE ON issue_labels
+  FOR EACH ROW EXECUTE FUNCTION serumpun_notify_change();
+
+DROP TRIGGER IF EXISTS serumpun_notify_change ON issue_comments;
+CREATE TRIGGER serumpun_notify_change
+  AFTER INSERT OR UPDATE OR DELETE ON issue_comments
+  FOR EACH ROW EXECUTE FUNCTION serumpun_notify_change();
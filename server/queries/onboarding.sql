@@ -0,0 +1,73 @@
+-- Onboarding: Invitation / Join Proyek per anggota directory, beserta jumlah
+-- issue yang di-assign di project
+WITH
+members AS (
+  -- Every directory CSV row with an email, passed as parallel arrays (see buildMemberArrays)
+  SELECT
+    email,
+    NULLIF(nama, '') AS nama,
+    NULLIF(instansi, '') AS instansi,
+    bidang,
+    NULLIF(jabatan, '') AS jabatan,
+    invited,
+    joined
+  FROM unnest({{MEMBERS}}) AS t(email, nama, instansi, bidang, jabatan, invited, joined)
+),
+
+assigned AS (
+  SELECT
+    LOWER(u.email) AS email,
+    COUNT(DISTINCT i.id) AS assigned_issues
+  FROM issues i
+  JOIN projects p ON p.id = i.project_id
+  JOIN workspaces w ON w.id = p.workspace_id
+  JOIN states s ON s.id = i.state_id
+  JOIN issue_assignees ia ON ia.issue_id = i.id AND ia.deleted_at IS NULL
+  JOIN users u ON u.id = ia.assignee_id
+  WHERE w.id = $1::uuid
+    AND p.id = $2::uuid
+    AND i.deleted_at IS NULL
+    AND s."group" != 'cancelled'
+  GROUP BY LOWER(u.email)
+),
+
+list AS (
+  SELECT
+    m.nama,
+    m.email,
+    m.instansi,
+    m.bidang,
+    m.jabatan,
+    m.invited,
+    m.joined,
+    COALESCE(a.assigned_issues, 0) AS assigned_issues,
+    CASE
+      WHEN m.joined AND COALESCE(a.assigned_issues, 0) > 0 THEN 'aktif'
+      WHEN m.joined THEN 'join_tanpa_issue'
+      WHEN m.invited THEN 'belum_join'
+      ELSE 'belum_diundang'
+    END AS status
+  FROM members m
+  LEFT JOIN assigned a ON a.email = m.email
+),
+filtered AS (
+  SELECT * FROM list{{WHERE_CLAUSE}}
+),
+
+summary AS (
+  SELECT
+    instansi,
+    bidang,
+    COUNT(*) AS total,
+    COUNT(*) FILTER (WHERE invited) AS invited,
+    COUNT(*) FILTER (WHERE joined) AS joined,
+    COUNT(*) FILTER (WHERE status = 'join_tanpa_issue') AS joined_no_issues,
+    COUNT(*) FILTER (WHERE status = 'belum_join') AS invited_not_joined,
+    COUNT(*) FILTER (WHERE status = 'belum_diundang') AS not_invited,
+    ROUND(100.0 * COUNT(*) FILTER (WHERE joined) / COUNT(*), 2) AS persen_join
+  FROM filtered
+  GROUP BY instansi, bidang
+)
+-- Result CTE chosen by ?view=; the unused one is never evaluated
+SELECT * FROM {{RESULT}}
+ORDER BY {{ORDER_BY}};
@@ -0,0 +1,13 @@
+-- Plane project members with their account email as stored (not normalised),
+-- so the directory reconciliation can spot case/whitespace differences
+SELECT
+  u.id::text AS user_id,
+  COALESCE(u.email, '') AS email,
+  COALESCE(u.display_name, '') AS display_name,
+  pm.is_active
+FROM project_members pm
+JOIN users u ON u.id = pm.member_id
+WHERE pm.workspace_id = $1::uuid
+  AND pm.project_id = $2::uuid
+  AND pm.deleted_at IS NULL
+ORDER BY u.email;
@@ -0,0 +1,17 @@
+-- KPI Snapshots: hasil harian kpi_provinsi, kpi_kabkot, heatmap dan workload,
+-- dipakai untuk ?compare=7d (nilai sebelumnya + delta).
+-- Dibuat otomatis oleh job snapshot (SNAPSHOT_ENABLED=true) atau ./api -snapshot.
+-- Satu baris per baris report; row_key adalah gabungan kolom identitas report.
+
+CREATE SCHEMA IF NOT EXISTS serumpun;
+
+CREATE TABLE IF NOT EXISTS serumpun.kpi_snapshots (
+  snapshot_date date        NOT NULL,
+  workspace_id  uuid        NOT NULL,
+  project_id    uuid        NOT NULL,
+  report        text        NOT NULL,
+  row_key       text        NOT NULL,
+  data          jsonb       NOT NULL,
+  created_at    timestamptz NOT NULL DEFAULT now(),
+  PRIMARY KEY (project_id, report, snapshot_date, row_key)
+);
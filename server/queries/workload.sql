@@ -1,5 +1,18 @@
 -- Workload: Distribution and balance check
 WITH 
+staff_directory AS (
+  -- Directory CSV passed as parallel text[] arguments (see buildDirectoryArrays)
+  SELECT
+    email,
+    NULLIF(nama, '') AS nama,
+    NULLIF(instansi, '') AS instansi,
+    NULLIF(scope, '') AS scope,
+    NULLIF(jabatan, '') AS jabatan,
+    NULLIF(bidang, '') AS bidang,
+    NULLIF(kab_kota, '') AS kab_kota
+  FROM unnest({{DIRECTORY}}) AS t(email, nama, instansi, scope, jabatan, bidang, kab_kota)
+),
+
 user_stats AS (
   SELECT
     ia.assignee_id,
@@ -21,8 +34,8 @@ user_stats AS (
   JOIN issue_assignees ia ON ia.issue_id = i.id AND ia.deleted_at IS NULL
   JOIN issue_labels il ON il.issue_id = i.id AND il.deleted_at IS NULL
   JOIN labels l ON l.id = il.label_id
-  WHERE w.id = '58f6ec9b-f0ae-4e68-8f05-8f1d9ddf9cac'
-    AND p.id = 'cfc12151-e169-4caf-bca9-3eb83ed588ee'
+  WHERE w.id = $1::uuid
+    AND p.id = $2::uuid
     AND i.deleted_at IS NULL
     AND s."group" != 'cancelled'
   GROUP BY ia.assignee_id, l.name
@@ -34,18 +47,10 @@ avg_workload AS (
 ),
 final AS (
   SELECT
-    CASE
-{{NAMA_CASES}}
-      ELSE COALESCE(u.display_name, u.first_name || ' ' || u.last_name, '-')
-    END AS nama,
-    CASE
-{{INSTANSI_CASES}}
-      ELSE '-'
-    END AS instansi,
-    CASE
-{{SCOPE_CASES}}
-      ELSE 'unknown'
-    END AS scope,
+    COALESCE(sd.nama, u.display_name, u.first_name || ' ' || u.last_name, '-') AS nama,
+    LOWER(u.email) AS email,
+    COALESCE(sd.instansi, '-') AS instansi,
+    COALESCE(sd.scope, 'unknown') AS scope,
     us.bidang,
     us.active_issues,
     us.completed_issues,
@@ -64,6 +69,7 @@ final AS (
   FROM user_stats us
   CROSS JOIN avg_workload aw
   JOIN users u ON u.id = us.assignee_id
+  LEFT JOIN staff_directory sd ON sd.email = LOWER(u.email)
 )
 SELECT * FROM final{{WHERE_CLAUSE}}
 ORDER BY 